@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+// adhocMetricsTTL is how long the counters of an /ipmi target are kept after
+// its last scrape. It is well above any sensible scrape interval, so the
+// counters of a target still being scraped are never reset.
+const adhocMetricsTTL = time.Hour
+
+// adhocTarget identifies an /ipmi target by the labels of its series.
+type adhocTarget struct {
+	host         string
+	instanceName string
+}
+
+// adhocMetrics holds the counters of the targets scraped through /ipmi, one
+// Metrics per target, so that each scrape serves the series of its own target
+// and they accumulate across scrapes like those on /metrics. Targets not
+// scraped for ttl are forgotten, so arbitrary targets don't pile up.
+type adhocMetrics struct {
+	namespace string
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	targets map[adhocTarget]*adhocMetricsEntry
+}
+
+type adhocMetricsEntry struct {
+	metrics  *ipmicollector.Metrics
+	lastUsed time.Time
+}
+
+func newAdhocMetrics(namespace string, ttl time.Duration) *adhocMetrics {
+	return &adhocMetrics{namespace: namespace, ttl: ttl, targets: make(map[adhocTarget]*adhocMetricsEntry)}
+}
+
+// get returns the counters of config for a scrape at now, creating them on
+// its first scrape, and forgets the targets idle for longer than the ttl.
+func (m *adhocMetrics) get(config ipmicollector.IPMIConfig, now time.Time) *ipmicollector.Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for target, entry := range m.targets {
+		if now.Sub(entry.lastUsed) > m.ttl {
+			delete(m.targets, target)
+		}
+	}
+	target := adhocTarget{host: config.Host, instanceName: config.InstanceName}
+	entry, ok := m.targets[target]
+	if !ok {
+		entry = &adhocMetricsEntry{metrics: ipmicollector.NewMetrics(m.namespace)}
+		m.targets[target] = entry
+	}
+	entry.lastUsed = now
+	return entry.metrics
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+// runConfigCheck validates the configuration for -check-config and writes a
+// summary to w. With collect set, every target is also queried once. It
+// returns the process exit code: non-zero if the configuration is invalid or
+// a target could not be collected from.
+func runConfigCheck(w io.Writer, collect bool, opts []ipmicollector.Option) int {
+	_, targets, err := loadTargets()
+	if err != nil {
+		fmt.Fprintf(w, "Configuration invalid: %v\n", err)
+		return 1
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(w, "Configuration OK: no targets configured, only /ipmi can be scraped")
+		return 0
+	}
+	fmt.Fprintf(w, "Configuration OK: %d target(s)\n", len(targets))
+	if !collect {
+		for _, target := range targets {
+			fmt.Fprintf(w, "  %s\n", target.Address())
+		}
+		return 0
+	}
+
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tSENSORS\tERROR")
+	for _, target := range targets {
+		collector := newCollector(target, opts)
+		if err := collector.Refresh(context.Background()); err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tunreachable\t-\t%v\n", target.Address(), err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\treachable\t%d\t\n", target.Address(), len(collector.Sensors()))
+	}
+	_ = tw.Flush()
+
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d target(s) unreachable\n", failed, len(targets))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the on-disk configuration passed via -config.file.
+type Config struct {
+	Targets []ipmicollector.IPMIConfig `yaml:"targets"`
+}
+
+// LoadConfig reads and validates the YAML configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Targets) == 0 {
+		return fmt.Errorf("no targets configured")
+	}
+
+	// Targets sharing a host or instance name would export the same series.
+	hosts := make(map[string]int, len(c.Targets))
+	instanceNames := make(map[string]int, len(c.Targets))
+	for i := range c.Targets {
+		target := &c.Targets[i]
+		target.Host = ipmicollector.NormalizeHost(target.Host)
+		if target.Host == "" {
+			return fmt.Errorf("target %d: host must be set", i)
+		}
+		if err := ipmicollector.ValidateHost(target.Host); err != nil {
+			return fmt.Errorf("target %d: %v", i, err)
+		}
+		if j, ok := hosts[target.Host]; ok {
+			return fmt.Errorf("targets %d and %d both have host %s", j, i, target.Host)
+		}
+		hosts[target.Host] = i
+		instanceName := cmp.Or(target.InstanceName, target.Host)
+		if j, ok := instanceNames[instanceName]; ok {
+			return fmt.Errorf("targets %d and %d both have instance name %s", j, i, instanceName)
+		}
+		instanceNames[instanceName] = i
+		if err := resolveCredentials(target, *ipmiCredentialsDir); err != nil {
+			return fmt.Errorf("target %s: %v", target.Host, err)
+		}
+		if err := ipmicollector.ValidateInterface(target.Interface); err != nil {
+			return fmt.Errorf("target %s: %v", target.Host, err)
+		}
+		if needsCredentials(*target) && (target.Username == "" || target.Password == "") {
+			return fmt.Errorf("target %s: username and password must be set", target.Host)
+		}
+		if err := ipmicollector.ValidateCipherSuite(target.CipherSuite); err != nil {
+			return fmt.Errorf("target %s: %v", target.Host, err)
+		}
+		if err := ipmicollector.ValidateOEM(target.OEM); err != nil {
+			return fmt.Errorf("target %s: %v", target.Host, err)
+		}
+		if target.Port == 0 {
+			target.Port = *ipmiPort
+		}
+	}
+
+	return nil
+}
+
+// resolveCredentials fills in a missing username or password from the files
+// <host>.user and <host>.pass in dir, as mounted from a Kubernetes secret.
+// Credentials already set on config take precedence. An empty dir disables
+// the lookup.
+func resolveCredentials(config *ipmicollector.IPMIConfig, dir string) error {
+	if dir == "" || !needsCredentials(*config) {
+		return nil
+	}
+
+	if config.Username == "" {
+		username, err := readCredentialFile(dir, config.Host+".user")
+		if err != nil {
+			return err
+		}
+		config.Username = username
+	}
+	if config.Password == "" {
+		password, err := readCredentialFile(dir, config.Host+".pass")
+		if err != nil {
+			return err
+		}
+		config.Password = password
+	}
+	return nil
+}
+
+func readCredentialFile(dir, name string) (string, error) {
+	// Hosts can come from scrape URLs, so never let one escape dir.
+	if filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid host for credential lookup")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential file from %s: %v", dir, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Target returns the configured target for host, if any.
+func (c *Config) Target(host string) (ipmicollector.IPMIConfig, bool) {
+	host = ipmicollector.NormalizeHost(host)
+	for _, target := range c.Targets {
+		if target.Host == host {
+			return target, true
+		}
+	}
+	return ipmicollector.IPMIConfig{}, false
+}
+
+// effectiveInterface returns the interface used for config, falling back to
+// -ipmi.interface.
+func effectiveInterface(config ipmicollector.IPMIConfig) string {
+	if config.Interface != "" {
+		return config.Interface
+	}
+	return *ipmiInterface
+}
+
+// needsCredentials reports whether config must have a username and password.
+// The open interface talks to the local BMC without them, and Redfish BMCs
+// can authenticate the exporter by its -redfish.tls-cert instead.
+func needsCredentials(config ipmicollector.IPMIConfig) bool {
+	if *sensorBackend == "redfish" {
+		return *redfishTLSCert == ""
+	}
+	return effectiveInterface(config) != "open"
+}
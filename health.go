@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxConsecutiveFailures is the number of failed background collections in a
+// row after which /readyz reports the exporter as not ready.
+const maxConsecutiveFailures = 3
+
+// readiness tracks the outcome of background collections for /readyz.
+type readiness struct {
+	mu                  sync.Mutex
+	required            bool
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// newReadiness returns a tracker. When no background collection is running
+// there is nothing to wait for and the exporter is ready straight away.
+func newReadiness(backgroundCollection bool) *readiness {
+	return &readiness{required: backgroundCollection}
+}
+
+// record registers the result of a background collection cycle across all
+// targets.
+func (r *readiness) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.consecutiveFailures++
+		return
+	}
+	r.lastSuccess = time.Now()
+	r.consecutiveFailures = 0
+}
+
+func (r *readiness) ready() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.required {
+		return true
+	}
+	return !r.lastSuccess.IsZero() && r.consecutiveFailures < maxConsecutiveFailures
+}
+
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK\n"))
+}
+
+func (r *readiness) readyzHandler(w http.ResponseWriter, _ *http.Request) {
+	if !r.ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK\n"))
+}
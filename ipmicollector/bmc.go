@@ -0,0 +1,70 @@
+package ipmicollector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// bmcInfo identifies the BMC as reported by `ipmitool mc info`.
+type bmcInfo struct {
+	Firmware     string
+	Manufacturer string
+	Product      string
+}
+
+// collectBMCInfo reads the device information of the BMC.
+func (c *Collector) collectBMCInfo(ctx context.Context) (*bmcInfo, error) {
+	output, err := c.executeIPMICommand(ctx, "mc", "info")
+	if err != nil {
+		return nil, err
+	}
+	return parseBMCInfo(output)
+}
+
+// parseBMCInfo parses `ipmitool mc info` output such as
+//
+//	Firmware Revision         : 3.80
+//	Manufacturer Name         : Super Micro Computer Inc.
+//	Product Name              : X11DPi-N
+func parseBMCInfo(output string) (*bmcInfo, error) {
+	var info bmcInfo
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Firmware Revision":
+			info.Firmware = value
+		case "Manufacturer Name":
+			info.Manufacturer = value
+		case "Product Name":
+			info.Product = value
+		}
+	}
+
+	if info.Firmware == "" {
+		return nil, fmt.Errorf("unexpected mc info output")
+	}
+	return &info, nil
+}
+
+// RefreshBMCInfo collects the BMC firmware and device information exported
+// as ipmi_bmc_info. It changes rarely, so it is refreshed separately from the
+// sensors and usually less often. On failure the previous information is
+// kept.
+func (c *Collector) RefreshBMCInfo(ctx context.Context) error {
+	info, err := c.collectBMCInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bmc = info
+	return nil
+}
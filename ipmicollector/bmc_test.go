@@ -0,0 +1,48 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBMCInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *bmcInfo
+		wantErr bool
+	}{
+		{
+			name: "supermicro",
+			output: `Device ID                 : 32
+Device Revision           : 1
+Firmware Revision         : 3.80
+IPMI Version              : 2.0
+Manufacturer ID           : 10876
+Manufacturer Name         : Super Micro Computer Inc.
+Product ID                : 6929 (0x1b11)
+Product Name              : X11DPi-N
+Device Available          : yes
+`,
+			want: &bmcInfo{Firmware: "3.80", Manufacturer: "Super Micro Computer Inc.", Product: "X11DPi-N"},
+		},
+		{
+			name:   "firmware only",
+			output: "Firmware Revision : 1.02\nManufacturer Name : Unknown (0x1234)\n",
+			want:   &bmcInfo{Firmware: "1.02", Manufacturer: "Unknown (0x1234)"},
+		},
+		{
+			name:    "unexpected output",
+			output:  "Get Device ID command failed\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBMCInfo(tt.output)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBMCInfo() = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
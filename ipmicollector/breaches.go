@@ -0,0 +1,34 @@
+package ipmicollector
+
+// sensorBreaches is the last known status of a sensor and how often it went
+// from ok into a threshold state.
+type sensorBreaches struct {
+	status string
+	count  float64
+}
+
+// trackBreaches returns the breach counts of sensors carried over from
+// previous, counting a breach for every sensor whose status went from ok to
+// nc, cr or nr. Sensors seen for the first time start at zero even when
+// already in breach, and statuses other than ok, nc, cr and nr, such as ns,
+// leave the last known status in place. Sensors no longer reported are
+// forgotten, as in trackChanges.
+func trackBreaches(previous map[changeKey]sensorBreaches, sensors []SensorData) map[changeKey]sensorBreaches {
+	breaches := make(map[changeKey]sensorBreaches, len(sensors))
+	for _, sensor := range sensors {
+		key := changeKey{sensor.Name, sensor.ID}
+		last, seen := previous[key]
+		if _, ok := sensorStatusValues[sensor.Status]; !ok {
+			if seen {
+				breaches[key] = last
+			}
+			continue
+		}
+		if seen && last.status == "ok" && sensor.Status != "ok" {
+			last.count++
+		}
+		last.status = sensor.Status
+		breaches[key] = last
+	}
+	return breaches
+}
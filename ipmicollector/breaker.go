@@ -0,0 +1,59 @@
+package ipmicollector
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Refresh while WithCircuitBreaker holds off
+// collections from a BMC that failed repeatedly.
+var ErrCircuitOpen = errors.New("collection held off after consecutive failures")
+
+// circuitBreaker holds off collections from a BMC after threshold
+// consecutive failures, for backoff at first and twice as long after every
+// further failure, up to maxBackoff. A zero threshold disables it.
+type circuitBreaker struct {
+	threshold  int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	failures int
+	retryAt  time.Time
+}
+
+// open reports whether the breaker holds off collections, apart from the
+// retry it lets through once the backoff has passed.
+func (b *circuitBreaker) open() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// allow reports whether a collection may run at now.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	return !b.open() || !now.Before(b.retryAt)
+}
+
+// record updates the breaker with the outcome of a collection started at
+// now. The backoff runs from the start rather than the end of the failed
+// collection, so a BMC that times out is retried after backoff and not
+// backoff plus the timeout. It returns how long collections are held off for
+// if the failure opened the breaker or a retry failed, and zero otherwise.
+func (b *circuitBreaker) record(err error, now time.Time) time.Duration {
+	if b.threshold == 0 {
+		return 0
+	}
+	if err == nil {
+		b.failures = 0
+		return 0
+	}
+	b.failures++
+	if !b.open() {
+		return 0
+	}
+	backoff := b.backoff
+	for i := b.threshold; i < b.failures && backoff < b.maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, b.maxBackoff)
+	b.retryAt = now.Add(backoff)
+	return backoff
+}
@@ -0,0 +1,76 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := circuitBreaker{threshold: 3, backoff: time.Minute, maxBackoff: 4 * time.Minute}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	errBMC := errors.New("no response from remote controller")
+
+	for i := range 2 {
+		if backoff := b.record(errBMC, start); backoff != 0 || b.open() {
+			t.Fatalf("failure %d: record() = %v, open() = %v; want the breaker closed", i+1, backoff, b.open())
+		}
+	}
+	if !b.allow(start) {
+		t.Fatal("allow() = false below the threshold")
+	}
+
+	// The third failure opens the breaker, and every failed retry doubles the
+	// backoff up to maxBackoff.
+	now := start
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		if backoff := b.record(errBMC, now); backoff != want {
+			t.Fatalf("record() = %v, want %v", backoff, want)
+		}
+		if !b.open() {
+			t.Fatal("open() = false after the threshold")
+		}
+		if b.allow(now.Add(want - time.Second)) {
+			t.Errorf("allow() = true %v into a %v backoff", want-time.Second, want)
+		}
+		// Half-open: one attempt is let through once the backoff has passed.
+		now = now.Add(want)
+		if !b.allow(now) {
+			t.Errorf("allow() = false after the %v backoff", want)
+		}
+	}
+
+	if backoff := b.record(nil, now); backoff != 0 || b.open() || !b.allow(now) {
+		t.Errorf("after a success: record() = %v, open() = %v; want the breaker closed", backoff, b.open())
+	}
+	if backoff := b.record(errBMC, now); backoff != 0 {
+		t.Errorf("first failure after a success: record() = %v, want the count restarted", backoff)
+	}
+
+	disabled := circuitBreaker{}
+	for range 10 {
+		disabled.record(errBMC, start)
+	}
+	if disabled.open() || !disabled.allow(start) {
+		t.Error("zero threshold: breaker opened, want it disabled")
+	}
+}
+
+func TestRefreshCircuitOpen(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	collector := NewCollector(IPMIConfig{Host: "bmc1"},
+		WithRunner(hangingRunner{}), WithTimeout(timeout), WithCircuitBreaker(1, time.Hour, time.Hour))
+
+	if err := collector.Refresh(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Refresh() = %v, want the timeout", err)
+	}
+	end := time.Now()
+	if err := collector.Refresh(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Refresh() after the failure = %v, want ErrCircuitOpen", err)
+	}
+	// The backoff is measured from the start of the timed out collection.
+	if wait := collector.breaker.retryAt.Sub(end); wait > time.Hour-timeout {
+		t.Errorf("retry %v after the failed collection, want the %v timeout deducted from the backoff", wait, timeout)
+	}
+}
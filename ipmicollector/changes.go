@@ -0,0 +1,42 @@
+package ipmicollector
+
+import (
+	"strings"
+	"time"
+)
+
+// changeKey identifies a sensor across refreshes.
+type changeKey struct{ name, id string }
+
+// sensorReading is the part of a sensor compared between refreshes.
+type sensorReading struct {
+	value  float64
+	states string
+}
+
+// sensorChange is the last reading of a sensor and when it first appeared.
+type sensorChange struct {
+	reading   sensorReading
+	changedAt time.Time
+}
+
+// trackChanges returns the change times of sensors collected at now: that of
+// previous for sensors whose reading is unchanged, and now for the rest.
+// Sensors no longer reported are forgotten, so the state stays proportional
+// to the number of sensors. Sensors without a reading are skipped.
+func trackChanges(previous map[changeKey]sensorChange, sensors []SensorData, now time.Time) map[changeKey]sensorChange {
+	changes := make(map[changeKey]sensorChange, len(sensors))
+	for _, sensor := range sensors {
+		if sensor.NoReading {
+			continue
+		}
+		key := changeKey{sensor.Name, sensor.ID}
+		reading := sensorReading{value: sensor.Value, states: strings.Join(sensor.States, ",")}
+		if last, ok := previous[key]; ok && last.reading == reading {
+			changes[key] = last
+			continue
+		}
+		changes[key] = sensorChange{reading: reading, changedAt: now}
+	}
+	return changes
+}
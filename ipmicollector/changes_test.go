@@ -0,0 +1,72 @@
+package ipmicollector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTrackChanges(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	refreshes := []struct {
+		sensors []SensorData
+		want    map[changeKey]time.Duration
+	}{
+		{
+			sensors: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Value: 45},
+				{Name: "PS1 Status", ID: "c8h", States: []string{"presence_detected"}},
+				{Name: "Fan2", ID: "31h", NoReading: true},
+			},
+			want: map[changeKey]time.Duration{{"CPU Temp", "01h"}: 0, {"PS1 Status", "c8h"}: 0},
+		},
+		{
+			// An unchanged reading keeps the time it first appeared.
+			sensors: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Value: 45},
+				{Name: "PS1 Status", ID: "c8h", States: []string{"presence_detected", "failure_detected"}},
+			},
+			want: map[changeKey]time.Duration{{"CPU Temp", "01h"}: 0, {"PS1 Status", "c8h"}: time.Minute},
+		},
+		{
+			// A sensor that disappears is forgotten, and starts over when it
+			// comes back.
+			sensors: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Value: 46},
+			},
+			want: map[changeKey]time.Duration{{"CPU Temp", "01h"}: 2 * time.Minute},
+		},
+		{
+			sensors: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Value: 46},
+				{Name: "PS1 Status", ID: "c8h", States: []string{"presence_detected", "failure_detected"}},
+			},
+			want: map[changeKey]time.Duration{{"CPU Temp", "01h"}: 2 * time.Minute, {"PS1 Status", "c8h"}: 3 * time.Minute},
+		},
+	}
+	var changes map[changeKey]sensorChange
+	for i, refresh := range refreshes {
+		changes = trackChanges(changes, refresh.sensors, start.Add(time.Duration(i)*time.Minute))
+		if len(changes) != len(refresh.want) {
+			t.Errorf("refresh %d: %d sensors tracked, want %d", i, len(changes), len(refresh.want))
+		}
+		for key, after := range refresh.want {
+			if got, want := changes[key].changedAt, start.Add(after); !got.Equal(want) {
+				t.Errorf("refresh %d: %v changed at %v, want %v", i, key, got, want)
+			}
+		}
+	}
+}
+
+func TestCollectorLastChange(t *testing.T) {
+	collector := fixtureCollector(t, nil, WithLastChange())
+	if got := testutil.CollectAndCount(collector, "ipmi_sensor_last_change_timestamp_seconds"); got != 10 {
+		t.Errorf("%d change timestamps, want one per sensor with a reading", got)
+	}
+
+	collector = fixtureCollector(t, nil)
+	if got := testutil.CollectAndCount(collector, "ipmi_sensor_last_change_timestamp_seconds"); got != 0 {
+		t.Errorf("%d change timestamps without WithLastChange, want none", got)
+	}
+}
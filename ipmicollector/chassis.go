@@ -0,0 +1,66 @@
+package ipmicollector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// chassisStatus is the state reported by `ipmitool chassis status`.
+type chassisStatus struct {
+	PowerOn           bool
+	FrontPanelLockout bool
+	// LastPowerEvent is the cause of the last power change, such as
+	// "ac-failed" or "command", and "none" when the BMC reports none.
+	LastPowerEvent string
+}
+
+// collectChassisStatus reads the chassis status of the BMC. BMCs that don't
+// implement the command yield no status and no error.
+func (c *Collector) collectChassisStatus(ctx context.Context) (*chassisStatus, error) {
+	output, err := c.executeIPMICommand(ctx, "chassis", "status")
+	if err != nil {
+		if isUnsupportedError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseChassisStatus(output)
+}
+
+// parseChassisStatus parses `ipmitool chassis status` output such as
+//
+//	System Power         : on
+//	Last Power Event     : ac-failed
+//	Front-Panel Lockout  : inactive
+func parseChassisStatus(output string) (*chassisStatus, error) {
+	var (
+		status    = chassisStatus{LastPowerEvent: "none"}
+		havePower bool
+	)
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "System Power":
+			status.PowerOn = value == "on"
+			havePower = true
+		case "Front-Panel Lockout":
+			status.FrontPanelLockout = value == "active"
+		case "Last Power Event":
+			if value != "" {
+				status.LastPowerEvent = value
+			}
+		}
+	}
+
+	if !havePower {
+		return nil, fmt.Errorf("unexpected chassis status output")
+	}
+	return &status, nil
+}
@@ -0,0 +1,48 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChassisStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *chassisStatus
+		wantErr bool
+	}{
+		{
+			name: "on after AC loss",
+			output: `System Power         : on
+Power Overload       : false
+Power Interlock      : inactive
+Main Power Fault     : false
+Power Control Fault  : false
+Power Restore Policy : always-off
+Last Power Event     : ac-failed
+Chassis Intrusion    : inactive
+Front-Panel Lockout  : active
+`,
+			want: &chassisStatus{PowerOn: true, FrontPanelLockout: true, LastPowerEvent: "ac-failed"},
+		},
+		{
+			name:   "off without event",
+			output: "System Power         : off\nLast Power Event     : \nFront-Panel Lockout  : inactive\n",
+			want:   &chassisStatus{LastPowerEvent: "none"},
+		},
+		{
+			name:    "unexpected output",
+			output:  "Error sending Chassis Status command\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChassisStatus(tt.output)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChassisStatus() = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,847 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sensorStatusValues encodes the sdr status column for ipmi_sensor_status.
+var sensorStatusValues = map[string]float64{
+	"ok": 0,
+	"nc": 1,
+	"cr": 2,
+	"nr": 3,
+}
+
+// Collector implements prometheus.Collector for a single BMC. Metrics are
+// built fresh on every scrape from the sensors of the last Refresh, so a
+// sensor that is no longer reported disappears instead of lingering.
+type Collector struct {
+	config IPMIConfig
+	opts   options
+	// metrics are the counters updated by this collector. ownMetrics is set
+	// when they were not passed in with WithMetrics and are exported by the
+	// collector itself.
+	metrics    *Metrics
+	ownMetrics bool
+
+	mu       sync.RWMutex
+	data     ipmiData
+	up       bool
+	duration time.Duration
+	// sessionDuration is the part of duration spent waiting on the BMC.
+	sessionDuration time.Duration
+	// collectedAt is when data was last refreshed successfully.
+	collectedAt time.Time
+	// lastSuccess is like collectedAt but survives clearOnError, so
+	// staleness stays observable.
+	lastSuccess time.Time
+	// bmc is refreshed separately from data, by RefreshBMCInfo.
+	bmc *bmcInfo
+	// changes holds the last reading of every sensor with WithLastChange.
+	changes map[changeKey]sensorChange
+	// energy holds the counters of the energy sensors with
+	// WithEnergyCounters.
+	energy map[changeKey]energyCounter
+	// dcmiEnergy is integrated from the DCMI power readings with
+	// WithEnergyCounters, nil until the first one.
+	dcmiEnergy *dcmiEnergy
+	// breaches holds the last status of every sensor with
+	// WithThresholdBreaches.
+	breaches map[changeKey]sensorBreaches
+	// rails holds the rail label of every voltage sensor with
+	// WithVoltageRails.
+	rails map[changeKey]string
+	// breaker is the WithCircuitBreaker state, updated by update.
+	breaker circuitBreaker
+
+	upDesc            *prometheus.Desc
+	durationDesc      *prometheus.Desc
+	sessionDesc       *prometheus.Desc
+	sensorCountDesc   *prometheus.Desc
+	ageDesc           *prometheus.Desc
+	lastSuccessDesc   *prometheus.Desc
+	circuitOpenDesc   *prometheus.Desc
+	fanRedundancyDesc *prometheus.Desc
+	stateDesc         *prometheus.Desc
+	statusDesc        *prometheus.Desc
+	presentDesc       *prometheus.Desc
+	progressDesc      *prometheus.Desc
+	infoDesc          *prometheus.Desc
+	lastChangeDesc    *prometheus.Desc
+	breachesDesc      *prometheus.Desc
+	energyDesc        *prometheus.Desc
+	dcmiPowerDesc     *prometheus.Desc
+	dcmiEnergyDesc    *prometheus.Desc
+	selEntriesDesc    *prometheus.Desc
+	selFreeDesc       *prometheus.Desc
+
+	bmcInfoDesc *prometheus.Desc
+
+	chassisPowerDesc      *prometheus.Desc
+	chassisLockoutDesc    *prometheus.Desc
+	chassisPowerEventDesc *prometheus.Desc
+
+	watchdogRunningDesc          *prometheus.Desc
+	watchdogCountdownDesc        *prometheus.Desc
+	watchdogInitialCountdownDesc *prometheus.Desc
+
+	// gauges are the descriptors of the numeric sensor types, by type.
+	gauges map[string]gaugeDescs
+}
+
+// NewCollector returns a collector for the BMC described by config. The host
+// is attached as a constant label, so collectors for different hosts can
+// share a registry. The collector serves no sensors until Refresh is called.
+func NewCollector(config IPMIConfig, opts ...Option) *Collector {
+	config = config.withDefaults()
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.hostLabel == "" {
+		o.hostLabel = config.Host
+	}
+	metrics, ownMetrics := o.metrics, false
+	if metrics == nil {
+		metrics, ownMetrics = NewMetrics(o.namespace), true
+	}
+
+	labels := []string{"sensor_name", "sensor_id"}
+	if o.entityLabel {
+		labels = append(labels, "entity")
+	}
+	if o.normalizeNames {
+		labels = append(labels, "sensor")
+	}
+	stateLabels := append(slices.Clone(labels), "state")
+	infoLabels := append(slices.Clone(labels), "status", "unit")
+	if !o.entityLabel {
+		infoLabels = append(infoLabels, "entity")
+	}
+	constLabels := prometheus.Labels{"host": o.hostLabel, "instance_name": config.InstanceName}
+	name := func(name string) string {
+		return prometheus.BuildFQName(o.namespace, "", name)
+	}
+
+	return &Collector{
+		config:     config,
+		opts:       o,
+		metrics:    metrics,
+		ownMetrics: ownMetrics,
+		breaker:    o.breaker,
+		upDesc: prometheus.NewDesc(
+			name("ipmi_up"),
+			"Whether the last collection from the BMC was successful",
+			nil, constLabels,
+		),
+		durationDesc: prometheus.NewDesc(
+			name("ipmi_scrape_duration_seconds"),
+			"Time taken by the last collection from the BMC, including parsing",
+			nil, constLabels,
+		),
+		sessionDesc: prometheus.NewDesc(
+			name("ipmi_session_duration_seconds"),
+			"Time the last collection spent running ipmitool or reading from the backend, summed over all commands and retries",
+			nil, constLabels,
+		),
+		sensorCountDesc: prometheus.NewDesc(
+			name("ipmi_sensors_collected"),
+			"Number of sensors successfully parsed in the last collection",
+			nil, constLabels,
+		),
+		ageDesc: prometheus.NewDesc(
+			name("ipmi_last_scrape_age_seconds"),
+			"Age of the cached sensor readings served for the BMC",
+			nil, constLabels,
+		),
+		lastSuccessDesc: prometheus.NewDesc(
+			name("ipmi_last_collection_timestamp_seconds"),
+			"Unix time of the last successful collection from the BMC",
+			nil, constLabels,
+		),
+		circuitOpenDesc: prometheus.NewDesc(
+			name("ipmi_host_circuit_open"),
+			"Whether collections from the BMC are held off after consecutive failures",
+			nil, constLabels,
+		),
+		fanRedundancyDesc: prometheus.NewDesc(
+			name("ipmi_fan_redundancy"),
+			"IPMI fan redundancy state of a fan zone: 0=fully redundant, 1=degraded, 2=redundancy lost, 3=non-redundant with sufficient fans, 4=non-redundant with insufficient fans, -1=unknown state",
+			[]string{"zone", "sensor_id"}, constLabels,
+		),
+		stateDesc: prometheus.NewDesc(
+			name("ipmi_sensor_state"),
+			"IPMI discrete sensor states, 1 if the state is asserted and 0 otherwise",
+			stateLabels, constLabels,
+		),
+		statusDesc: prometheus.NewDesc(
+			name("ipmi_sensor_status"),
+			"IPMI sensor status: 0=ok, 1=non-critical, 2=critical, 3=non-recoverable",
+			labels, constLabels,
+		),
+		presentDesc: prometheus.NewDesc(
+			name("ipmi_sensor_present"),
+			"Whether the IPMI sensor has a reading, 0 if it reports No Reading or Disabled",
+			labels, constLabels,
+		),
+		progressDesc: prometheus.NewDesc(
+			name("ipmi_system_firmware_progress"),
+			"POST and boot progress of the system firmware, 1 for each state asserted by the IPMI sensor, such as system_boot_initiated",
+			stateLabels, constLabels,
+		),
+		infoDesc: prometheus.NewDesc(
+			name("ipmi_sensor_info"),
+			"A metric with a constant '1' value labeled by the status, unit and entity of the IPMI sensor as reported by ipmitool",
+			infoLabels, constLabels,
+		),
+		gauges: newGaugeDescs(sensorGauges(o), labels, name, constLabels),
+		lastChangeDesc: prometheus.NewDesc(
+			name("ipmi_sensor_last_change_timestamp_seconds"),
+			"Unix time of the first collection that returned the current reading of the IPMI sensor",
+			labels, constLabels,
+		),
+		breachesDesc: prometheus.NewDesc(
+			name("ipmi_sensor_threshold_breaches_total"),
+			"Number of times the status of the IPMI sensor went from ok to nc, cr or nr",
+			labels, constLabels,
+		),
+		energyDesc: prometheus.NewDesc(
+			name("ipmi_energy_joules_total"),
+			"IPMI energy sensor readings in joules, counted on across resets of the total by the BMC",
+			labels, constLabels,
+		),
+		dcmiPowerDesc: prometheus.NewDesc(
+			name("ipmi_dcmi_power_watts"),
+			"IPMI DCMI system power readings in watts, with the sampling period the BMC averages over",
+			[]string{"type", "period"}, constLabels,
+		),
+		dcmiEnergyDesc: prometheus.NewDesc(
+			name("ipmi_dcmi_energy_joules_total"),
+			"IPMI DCMI system energy consumption in joules, integrated from the DCMI power readings of the collections",
+			nil, constLabels,
+		),
+		selEntriesDesc: prometheus.NewDesc(
+			name("ipmi_sel_entries"),
+			"Number of entries in the IPMI System Event Log",
+			nil, constLabels,
+		),
+		selFreeDesc: prometheus.NewDesc(
+			name("ipmi_sel_free_space_percent"),
+			"Free space left in the IPMI System Event Log in percent",
+			nil, constLabels,
+		),
+		bmcInfoDesc: prometheus.NewDesc(
+			name("ipmi_bmc_info"),
+			"A metric with a constant '1' value labeled by the BMC firmware revision, manufacturer and product",
+			[]string{"firmware", "manufacturer", "product"}, constLabels,
+		),
+		chassisPowerDesc: prometheus.NewDesc(
+			name("ipmi_chassis_power_state"),
+			"Chassis power state, 1 if the system is powered on and 0 otherwise",
+			nil, constLabels,
+		),
+		chassisLockoutDesc: prometheus.NewDesc(
+			name("ipmi_chassis_front_panel_lockout"),
+			"Whether the chassis front-panel buttons are locked out",
+			nil, constLabels,
+		),
+		chassisPowerEventDesc: prometheus.NewDesc(
+			name("ipmi_chassis_last_power_event"),
+			"Cause of the last chassis power change as reported by the BMC, always 1",
+			[]string{"event"}, constLabels,
+		),
+		watchdogRunningDesc: prometheus.NewDesc(
+			name("ipmi_watchdog_running"),
+			"Whether the BMC watchdog timer is running, with the action it takes on expiry",
+			[]string{"action"}, constLabels,
+		),
+		watchdogCountdownDesc: prometheus.NewDesc(
+			name("ipmi_watchdog_countdown_seconds"),
+			"Seconds left before the BMC watchdog timer expires",
+			nil, constLabels,
+		),
+		watchdogInitialCountdownDesc: prometheus.NewDesc(
+			name("ipmi_watchdog_initial_countdown_seconds"),
+			"Seconds the BMC watchdog timer counts down from when reset",
+			nil, constLabels,
+		),
+	}
+}
+
+// Config returns the config of the BMC, with defaults filled in.
+func (c *Collector) Config() IPMIConfig {
+	return c.config
+}
+
+// HostLabel returns the value of the "host" label of the collector's metrics.
+func (c *Collector) HostLabel() string {
+	return c.opts.hostLabel
+}
+
+// Sensors returns the sensors of the last successful refresh.
+func (c *Collector) Sensors() []SensorData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return slices.Clone(c.data.Sensors)
+}
+
+// ErrCollectionInProgress is returned by Refresh when a collection from the
+// same BMC is still running.
+var ErrCollectionInProgress = errors.New("collection already in progress")
+
+// inFlight holds the addresses of BMCs with a collection running, so that
+// overlapping cycles or scrapes don't open concurrent sessions, which some
+// BMCs reject. It is shared by all collectors in the process.
+var inFlight sync.Map
+
+// Refresh collects from the BMC and replaces the readings served by Collect.
+// A failed collection sets ipmi_up to 0 and is returned. If ctx is cancelled
+// the previous readings are kept and ctx.Err() is returned. While
+// WithCircuitBreaker holds off collections, ErrCircuitOpen is returned
+// without contacting the BMC.
+func (c *Collector) Refresh(ctx context.Context) error {
+	address := c.config.Address()
+	if _, busy := inFlight.LoadOrStore(address, struct{}{}); busy {
+		c.metrics.collectionSkippedTotal.WithLabelValues(c.targetLabels()...).Inc()
+		slog.Warn("Skipping collection, the previous one is still running", "host", c.config.Host)
+		return ErrCollectionInProgress
+	}
+	defer inFlight.Delete(address)
+
+	start := time.Now()
+	c.mu.RLock()
+	allowed := c.breaker.allow(start)
+	c.mu.RUnlock()
+	if !allowed {
+		slog.Debug("Skipping collection, circuit open", "host", c.config.Host)
+		return ErrCircuitOpen
+	}
+
+	ctx, session := withSessionTimer(ctx)
+	data, err := c.collectIPMIData(ctx)
+	if ctx.Err() != nil {
+		// Shutting down or the scrape was abandoned; keep the last result.
+		slog.Debug("Collection cancelled", "host", c.config.Host, "err", err)
+		return ctx.Err()
+	}
+	c.update(data, start, *session, err)
+	if err != nil {
+		slog.Error("Failed to execute IPMI command", "host", c.config.Host, "err", err)
+		return err
+	}
+
+	slog.Debug("Updated sensor metrics", "host", c.config.Host, "sensors", len(data.Sensors))
+	return nil
+}
+
+// sessionTimerKey is the context key of the time accumulated by
+// addSessionTime during a Refresh.
+type sessionTimerKey struct{}
+
+// withSessionTimer returns a context in which addSessionTime sums up the time
+// spent waiting on the BMC, excluding parsing and retry backoff. Commands run
+// sequentially within a Refresh, so the total needs no locking.
+func withSessionTimer(ctx context.Context) (context.Context, *time.Duration) {
+	total := new(time.Duration)
+	return context.WithValue(ctx, sessionTimerKey{}, total), total
+}
+
+// addSessionTime adds d to the session timer of ctx, if it has one.
+func addSessionTime(ctx context.Context, d time.Duration) {
+	if total, ok := ctx.Value(sessionTimerKey{}).(*time.Duration); ok {
+		*total += d
+	}
+}
+
+// ipmiData holds everything gathered from a BMC in one collection cycle.
+type ipmiData struct {
+	Sensors []SensorData
+	// DCMIPower is nil when DCMI is disabled or unsupported.
+	DCMIPower *dcmiPower
+	// SEL is nil when SEL collection is disabled or failed.
+	SEL *selInfo
+	// Chassis is nil when chassis collection is disabled, unsupported or
+	// failed.
+	Chassis *chassisStatus
+	// Watchdog is nil when watchdog collection is disabled, unsupported or
+	// failed.
+	Watchdog *watchdogStatus
+}
+
+func (c *Collector) collectIPMIData(ctx context.Context) (ipmiData, error) {
+	sensors, err := c.collectSensors(ctx)
+	if err != nil {
+		return ipmiData{}, err
+	}
+	data := ipmiData{Sensors: sensors}
+
+	if c.opts.dcmi {
+		data.DCMIPower, err = c.collectDCMIPower(ctx)
+		if err != nil {
+			slog.Error("Failed to collect DCMI power reading", "host", c.config.Host, "err", err)
+		}
+	}
+
+	if c.opts.sel {
+		data.SEL, err = c.collectSELInfo(ctx)
+		if err != nil {
+			slog.Error("Failed to collect SEL info", "host", c.config.Host, "err", err)
+		}
+	}
+
+	if c.opts.chassis {
+		data.Chassis, err = c.collectChassisStatus(ctx)
+		if err != nil {
+			slog.Error("Failed to collect chassis status", "host", c.config.Host, "err", err)
+		}
+	}
+
+	if c.opts.watchdog {
+		data.Watchdog, err = c.collectWatchdog(ctx)
+		if err != nil {
+			slog.Error("Failed to collect watchdog status", "host", c.config.Host, "err", err)
+		}
+	}
+
+	return data, nil
+}
+
+func (c *Collector) collectSensors(ctx context.Context) ([]SensorData, error) {
+	if c.opts.backend != nil {
+		return c.collectBackendSensors(ctx)
+	}
+
+	sensors, err := c.listSensors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.thresholds {
+		// Thresholds are supplementary, so a failure here keeps the readings.
+		output, err := c.executeIPMICommand(ctx, "sensor")
+		if err != nil {
+			slog.Error("Failed to collect sensor thresholds", "host", c.config.Host, "err", err)
+		} else {
+			applyThresholds(sensors, parseSensorThresholds(output))
+		}
+	}
+
+	return sensors, nil
+}
+
+// listSensors reads the sensors with the listing of the WithSDRFormat
+// format, or with one `sdr type` per selected type under
+// WithSDRTypeQueries.
+func (c *Collector) listSensors(ctx context.Context) ([]SensorData, error) {
+	queries, format := [][]string{c.opts.sdrFormat.args}, c.opts.sdrFormat
+	if c.opts.sdrTypeQueries {
+		if types := sdrTypeQueries(c.opts.types); types != nil {
+			// sdr type prints the same columns as sdr elist.
+			queries, format = queries[:0], sdrFormats[0]
+			for _, sdrType := range types {
+				queries = append(queries, []string{"sdr", "type", sdrType})
+			}
+		}
+	}
+
+	var sensors []SensorData
+	for _, args := range queries {
+		output, err := c.executeIPMICommand(ctx, args...)
+		if err != nil && (output == "" || ctx.Err() != nil) {
+			return nil, err
+		}
+		parsed, parseErrors, filtered := parseSensorData(output, format, c.opts.filter)
+		if err != nil {
+			// Some BMCs fail the command over a single sensor after
+			// printing all the others.
+			if len(parsed) == 0 {
+				return nil, err
+			}
+			slog.Warn("ipmitool failed after listing sensors, using the ones it printed", "host", c.config.Host, "sensors", len(parsed), "err", err)
+		}
+		c.metrics.sensorParseErrorsTotal.WithLabelValues(c.targetLabels()...).Add(float64(parseErrors))
+		c.metrics.sensorsFilteredTotal.WithLabelValues(c.targetLabels()...).Add(float64(filtered))
+		sensors = append(sensors, parsed...)
+	}
+	if len(queries) > 1 {
+		disambiguateSensorIDs(sensors)
+	}
+	return sensors, nil
+}
+
+// collectBackendSensors reads the sensors from the WithBackend backend,
+// bounded by the WithTimeout timeout.
+func (c *Collector) collectBackendSensors(ctx context.Context) ([]SensorData, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.timeout)
+	defer cancel()
+
+	start := time.Now()
+	all, err := c.opts.backend.ReadSensors(ctx, c.config)
+	addSessionTime(ctx, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	sensors := make([]SensorData, 0, len(all))
+	for _, sensor := range all {
+		if c.opts.filter.allows(sensor.Name) {
+			sensors = append(sensors, sensor)
+		}
+	}
+	c.metrics.sensorsFilteredTotal.WithLabelValues(c.targetLabels()...).Add(float64(len(all) - len(sensors)))
+	disambiguateSensorIDs(sensors)
+	return sensors, nil
+}
+
+// update records the outcome of a collection cycle started at start. On
+// failure ipmi_up drops to 0 and the previous sensor readings are kept, or
+// dropped with clearOnError.
+func (c *Collector) update(data ipmiData, start time.Time, sessionDuration time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.up = err == nil
+	c.duration = time.Since(start)
+	c.sessionDuration = sessionDuration
+	if backoff := c.breaker.record(err, start); backoff > 0 {
+		slog.Warn("Holding off collections after consecutive failures", "host", c.config.Host, "failures", c.breaker.failures, "retry_in", backoff)
+	}
+	switch {
+	case err == nil:
+		c.data = data
+		c.collectedAt = time.Now()
+		c.lastSuccess = c.collectedAt
+		if c.opts.trackChanges {
+			c.changes = trackChanges(c.changes, data.Sensors, c.collectedAt)
+		}
+		if c.opts.trackBreaches {
+			c.breaches = trackBreaches(c.breaches, data.Sensors)
+		}
+		if c.opts.energyCounters {
+			c.energy = trackEnergy(c.energy, data.Sensors)
+			if data.DCMIPower != nil {
+				c.dcmiEnergy = integrateDCMIEnergy(c.dcmiEnergy, data.DCMIPower, c.collectedAt)
+			}
+		}
+		if len(c.opts.rails) > 0 {
+			c.rails = trackRails(c.rails, data.Sensors, c.opts.rails)
+		}
+	case c.opts.clearOnError:
+		c.data = ipmiData{}
+		c.collectedAt = time.Time{}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.upDesc
+	ch <- c.durationDesc
+	ch <- c.sessionDesc
+	ch <- c.sensorCountDesc
+	ch <- c.ageDesc
+	ch <- c.lastSuccessDesc
+	if c.breaker.threshold > 0 {
+		ch <- c.circuitOpenDesc
+	}
+	ch <- c.fanRedundancyDesc
+	ch <- c.stateDesc
+	ch <- c.statusDesc
+	ch <- c.presentDesc
+	ch <- c.progressDesc
+	if c.opts.trackChanges {
+		ch <- c.lastChangeDesc
+	}
+	if c.opts.trackBreaches {
+		ch <- c.breachesDesc
+	}
+	if c.opts.energyCounters {
+		ch <- c.energyDesc
+	}
+	if c.opts.sensorInfo {
+		ch <- c.infoDesc
+	}
+	ch <- c.dcmiPowerDesc
+	if c.opts.energyCounters {
+		ch <- c.dcmiEnergyDesc
+	}
+	ch <- c.selEntriesDesc
+	ch <- c.selFreeDesc
+	ch <- c.bmcInfoDesc
+	ch <- c.chassisPowerDesc
+	ch <- c.chassisLockoutDesc
+	ch <- c.chassisPowerEventDesc
+	ch <- c.watchdogRunningDesc
+	ch <- c.watchdogCountdownDesc
+	ch <- c.watchdogInitialCountdownDesc
+	for _, gauge := range c.gauges {
+		ch <- gauge.value
+		if gauge.threshold != nil {
+			ch <- gauge.threshold
+		}
+	}
+	if c.ownMetrics {
+		c.metrics.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector. It only reads the snapshot of the
+// last Refresh and never runs ipmitool itself.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if c.ownMetrics {
+		// Deferred so series dropped below are already counted.
+		defer c.metrics.Collect(ch)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, boolValue(c.up))
+	ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, c.duration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.sessionDesc, prometheus.GaugeValue, c.sessionDuration.Seconds())
+	if c.breaker.threshold > 0 {
+		ch <- prometheus.MustNewConstMetric(c.circuitOpenDesc, prometheus.GaugeValue, boolValue(c.breaker.open()))
+	}
+	if !c.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.lastSuccessDesc, prometheus.GaugeValue, float64(c.lastSuccess.UnixNano())/1e9)
+	}
+	if bmc := c.bmc; bmc != nil {
+		ch <- prometheus.MustNewConstMetric(c.bmcInfoDesc, prometheus.GaugeValue, 1, bmc.Firmware, bmc.Manufacturer, bmc.Product)
+	}
+
+	if c.collectedAt.IsZero() {
+		return
+	}
+	age := time.Since(c.collectedAt)
+	ch <- prometheus.MustNewConstMetric(c.ageDesc, prometheus.GaugeValue, age.Seconds())
+	if c.opts.cacheTTL > 0 && age > c.opts.cacheTTL {
+		return
+	}
+
+	read := 0
+	for _, sensor := range c.data.Sensors {
+		if c.selected(sensor) && !sensor.NoReading {
+			read++
+		}
+	}
+	// Sent before the series limit applies, like ipmi_up, but only counting
+	// the sensors of the types collectData exports.
+	c.send(ch, c.sensorCountDesc, float64(read))
+
+	if c.opts.maxSeries <= 0 {
+		c.collectData(ch)
+		return
+	}
+	c.collectLimited(ch)
+}
+
+// collectLimited runs collectData, passing on only the first maxSeries
+// series and counting the rest as dropped.
+func (c *Collector) collectLimited(ch chan<- prometheus.Metric) {
+	limited := make(chan prometheus.Metric)
+	dropped := make(chan int)
+	go func() {
+		sent, n := 0, 0
+		for metric := range limited {
+			if sent < c.opts.maxSeries {
+				ch <- metric
+				sent++
+			} else {
+				n++
+			}
+		}
+		dropped <- n
+	}()
+	c.collectData(limited)
+	close(limited)
+
+	if n := <-dropped; n > 0 {
+		c.metrics.seriesDroppedTotal.WithLabelValues(c.targetLabels()...).Add(float64(n))
+		slog.Warn("Dropped sensor series over the limit", "host", c.config.Host, "limit", c.opts.maxSeries, "dropped", n)
+	}
+}
+
+// collectData emits the metrics derived from the cached snapshot.
+func (c *Collector) collectData(ch chan<- prometheus.Metric) {
+	if dcmi := c.data.DCMIPower; dcmi != nil {
+		for level, value := range dcmi.Readings {
+			c.send(ch, c.dcmiPowerDesc, value, level, dcmi.Period)
+		}
+	}
+	if energy := c.dcmiEnergy; energy != nil {
+		c.sendValue(ch, c.dcmiEnergyDesc, prometheus.CounterValue, energy.joules)
+	}
+
+	if sel := c.data.SEL; sel != nil {
+		c.send(ch, c.selEntriesDesc, sel.Entries)
+		c.send(ch, c.selFreeDesc, sel.FreeSpacePercent)
+	}
+
+	if chassis := c.data.Chassis; chassis != nil {
+		c.send(ch, c.chassisPowerDesc, boolValue(chassis.PowerOn))
+		c.send(ch, c.chassisLockoutDesc, boolValue(chassis.FrontPanelLockout))
+		c.send(ch, c.chassisPowerEventDesc, 1, chassis.LastPowerEvent)
+	}
+
+	if watchdog := c.data.Watchdog; watchdog != nil {
+		c.send(ch, c.watchdogRunningDesc, boolValue(watchdog.Running), watchdog.Action)
+		c.send(ch, c.watchdogCountdownDesc, watchdog.PresentCountdown)
+		c.send(ch, c.watchdogInitialCountdownDesc, watchdog.InitialCountdown)
+	}
+
+	for _, sensor := range c.data.Sensors {
+		if !c.selected(sensor) {
+			continue
+		}
+		if c.opts.sensorInfo {
+			c.send(ch, c.infoDesc, 1, c.infoLabels(sensor)...)
+		}
+		if sensor.NoReading {
+			c.send(ch, c.presentDesc, 0, c.sensorLabels(sensor)...)
+			continue
+		}
+		c.send(ch, c.presentDesc, 1, c.sensorLabels(sensor)...)
+		if change, ok := c.changes[changeKey{sensor.Name, sensor.ID}]; ok {
+			c.send(ch, c.lastChangeDesc, float64(change.changedAt.UnixNano())/1e9, c.sensorLabels(sensor)...)
+		}
+
+		if status, ok := sensorStatusValues[sensor.Status]; ok {
+			c.send(ch, c.statusDesc, status, c.sensorLabels(sensor)...)
+		}
+		if breaches, ok := c.breaches[changeKey{sensor.Name, sensor.ID}]; ok {
+			c.sendValue(ch, c.breachesDesc, prometheus.CounterValue, breaches.count, c.sensorLabels(sensor)...)
+		}
+
+		if sensor.Type == "system_firmware_progress" {
+			// Of the many progress states only the current one is exported.
+			for _, state := range sensor.States {
+				c.send(ch, c.progressDesc, 1, c.sensorLabels(sensor, state)...)
+			}
+			continue
+		}
+		if discrete, ok := lookupDiscreteSensorType(sensor.Type); ok {
+			c.collectStates(ch, sensor, discrete)
+			continue
+		}
+		if sensor.Type == "fan_redundancy" {
+			c.send(ch, c.fanRedundancyDesc, sensor.Value, sensor.Name, sensor.ID)
+			continue
+		}
+
+		if sensor.Type == "energy" && c.opts.energyCounters {
+			if counter, ok := c.energy[changeKey{sensor.Name, sensor.ID}]; ok {
+				c.sendValue(ch, c.energyDesc, prometheus.CounterValue, counter.value(), c.sensorLabels(sensor)...)
+			}
+			continue
+		}
+
+		gauge, ok := c.gauges[sensor.Type]
+		if !ok {
+			continue
+		}
+		labels := c.sensorLabels(sensor)
+		if gauge.label != "" {
+			labels = append(labels, c.gaugeLabelValue(gauge.label, sensor))
+		}
+		c.send(ch, gauge.value, c.convert(sensor.Type, sensor.Value), labels...)
+
+		if gauge.threshold != nil {
+			for level, value := range sensor.Thresholds {
+				c.send(ch, gauge.threshold, c.convert(sensor.Type, value), c.sensorLabels(sensor, level)...)
+			}
+		}
+	}
+}
+
+func (c *Collector) collectStates(ch chan<- prometheus.Metric, sensor SensorData, discrete discreteSensorType) {
+	for _, state := range discrete.stateLabels() {
+		value := 0.0
+		if slices.Contains(sensor.States, state) {
+			value = 1
+		}
+		c.send(ch, c.stateDesc, value, c.sensorLabels(sensor, state)...)
+	}
+}
+
+// send emits a gauge from the cached snapshot, stamped with the collection
+// time when timestamps are enabled.
+func (c *Collector) send(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	c.sendValue(ch, desc, prometheus.GaugeValue, value, labels...)
+}
+
+// sendValue is like send for metrics of any value type.
+func (c *Collector) sendValue(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labels ...string) {
+	metric := prometheus.MustNewConstMetric(desc, valueType, value, labels...)
+	if c.opts.timestamps {
+		metric = prometheus.NewMetricWithTimestamp(c.collectedAt, metric)
+	}
+	ch <- metric
+}
+
+// targetLabels returns the label values identifying the BMC in the Metrics
+// counters, followed by extra.
+func (c *Collector) targetLabels(extra ...string) []string {
+	return append([]string{c.opts.hostLabel, c.config.InstanceName}, extra...)
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sensorLabels returns the label values identifying sensor, followed by
+// extra.
+func (c *Collector) sensorLabels(sensor SensorData, extra ...string) []string {
+	values := []string{sensor.Name, sensor.ID}
+	if c.opts.entityLabel {
+		values = append(values, sensor.Entity)
+	}
+	if c.opts.normalizeNames {
+		values = append(values, normalizeSensorName(sensor.Name))
+	}
+	return append(values, extra...)
+}
+
+// selected reports whether sensor is of a type chosen by WithSensorTypes.
+func (c *Collector) selected(sensor SensorData) bool {
+	return c.opts.types == nil || c.opts.types[sensorCategory(sensor.Type)]
+}
+
+// infoLabels returns the label values of ipmi_sensor_info for sensor. The
+// entity is only added when WithEntityLabel doesn't already add it.
+func (c *Collector) infoLabels(sensor SensorData) []string {
+	values := c.sensorLabels(sensor, sensor.Status, sensor.Unit)
+	if !c.opts.entityLabel {
+		values = append(values, sensor.Entity)
+	}
+	return values
+}
+
+// convert returns value in the unit the collector exports sensorType in.
+func (c *Collector) convert(sensorType string, value float64) float64 {
+	if sensorType == "temperature" {
+		return c.opts.temperatureUnit.convert(value)
+	}
+	return value
+}
+
+// nonAlphanumeric matches runs of characters not allowed in a normalized
+// sensor name.
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// normalizeSensorName turns vendor names such as "CPU1 Temp" or "Fan-1A"
+// into "cpu1_temp" and "fan_1a".
+func normalizeSensorName(name string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
@@ -0,0 +1,576 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fixtureRunner serves the FileRunner fixture at path, which a test changes
+// between refreshes to have the BMC report different sensors.
+type fixtureRunner struct {
+	path string
+}
+
+// Run implements CommandRunner.
+func (r *fixtureRunner) Run(ctx context.Context, args []string, stdin string) ([]byte, []byte, error) {
+	return FileRunner{Path: r.path}.Run(ctx, args, stdin)
+}
+
+// fixtureCollector returns a collector for bmc1 refreshed successfully from
+// each of fixtures in turn, or just from testdata/sdr_elist.txt without them.
+func fixtureCollector(t *testing.T, fixtures []string, opts ...Option) *Collector {
+	t.Helper()
+	if len(fixtures) == 0 {
+		fixtures = []string{"testdata/sdr_elist.txt"}
+	}
+	runner := &fixtureRunner{}
+	opts = append([]Option{WithRunner(runner)}, opts...)
+	collector := NewCollector(IPMIConfig{Host: "bmc1"}, opts...)
+	for _, fixture := range fixtures {
+		runner.path = fixture
+		if err := collector.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return collector
+}
+
+func TestCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures []string
+		opts     []Option
+		metrics  []string
+		want     string
+	}{
+		{
+			name:    "sensors",
+			metrics: []string{"ipmi_up", "ipmi_sensors_collected", "ipmi_sensor_parse_errors_total", "ipmi_temperature_celsius", "ipmi_fan_speed_rpm", "ipmi_power_watts", "ipmi_sensor_present"},
+			want: `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="bmc1",instance_name="bmc1"} 1
+# HELP ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE ipmi_sensors_collected gauge
+ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 10
+# HELP ipmi_sensor_parse_errors_total Number of sdr lines that matched the sensor format but whose value could not be parsed
+# TYPE ipmi_sensor_parse_errors_total counter
+ipmi_sensor_parse_errors_total{host="bmc1",instance_name="bmc1"} 1
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 23
+# HELP ipmi_fan_speed_rpm IPMI fan speed sensor readings in RPM
+# TYPE ipmi_fan_speed_rpm gauge
+ipmi_fan_speed_rpm{fan_id="Fan1",host="bmc1",instance_name="bmc1",sensor_id="30h",sensor_name="Fan1 RPM"} 5400
+# HELP ipmi_power_watts IPMI power sensor readings in watts, with direction input or output for power supply sensors
+# TYPE ipmi_power_watts gauge
+ipmi_power_watts{direction="input",host="bmc1",instance_name="bmc1",sensor_id="70h",sensor_name="PS1 Input Power"} 220
+# HELP ipmi_sensor_present Whether the IPMI sensor has a reading, 0 if it reports No Reading or Disabled
+# TYPE ipmi_sensor_present gauge
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="20h",sensor_name="12V"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="30h",sensor_name="Fan1 RPM"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="31h",sensor_name="Fan1 Duty"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="70h",sensor_name="PS1 Input Power"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="71h",sensor_name="PS1 Current"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="72h",sensor_name="Humidity"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="80h",sensor_name="Disk 3"} 0
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status"} 1
+ipmi_sensor_present{host="bmc1",instance_name="bmc1",sensor_id="d0h",sensor_name="OEM Raw"} 1
+`,
+		},
+		{
+			name:     "vanished sensors",
+			fixtures: []string{"testdata/sdr_elist.txt", "testdata/sdr_elist_vanished.txt"},
+			metrics:  []string{"ipmi_sensors_collected", "ipmi_temperature_celsius", "ipmi_fan_speed_rpm"},
+			want: `
+# HELP ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE ipmi_sensors_collected gauge
+ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 8
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45
+`,
+		},
+		{
+			// ipmitool on Windows, or an SSH relay with a terminal, ends lines
+			// with CRLF.
+			name:     "crlf line endings",
+			fixtures: []string{"testdata/sdr_elist_crlf.txt"},
+			metrics:  []string{"ipmi_sensors_collected", "ipmi_sensor_parse_errors_total", "ipmi_temperature_celsius", "ipmi_fan_speed_rpm"},
+			want: `
+# HELP ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE ipmi_sensors_collected gauge
+ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 10
+# HELP ipmi_sensor_parse_errors_total Number of sdr lines that matched the sensor format but whose value could not be parsed
+# TYPE ipmi_sensor_parse_errors_total counter
+ipmi_sensor_parse_errors_total{host="bmc1",instance_name="bmc1"} 1
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 23
+# HELP ipmi_fan_speed_rpm IPMI fan speed sensor readings in RPM
+# TYPE ipmi_fan_speed_rpm gauge
+ipmi_fan_speed_rpm{fan_id="Fan1",host="bmc1",instance_name="bmc1",sensor_id="30h",sensor_name="Fan1 RPM"} 5400
+`,
+		},
+		{
+			// The temperatures cross their thresholds twice, staying in
+			// breach over the third refresh.
+			name:     "threshold breaches",
+			fixtures: []string{"testdata/sdr_elist.txt", "testdata/sdr_elist_breach.txt", "testdata/sdr_elist_breach.txt", "testdata/sdr_elist.txt", "testdata/sdr_elist_breach.txt"},
+			opts:     []Option{WithThresholdBreaches(), WithSensorTypes("temperature")},
+			metrics:  []string{"ipmi_sensor_threshold_breaches_total"},
+			want: `
+# HELP ipmi_sensor_threshold_breaches_total Number of times the status of the IPMI sensor went from ok to nc, cr or nr
+# TYPE ipmi_sensor_threshold_breaches_total counter
+ipmi_sensor_threshold_breaches_total{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 2
+ipmi_sensor_threshold_breaches_total{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 2
+`,
+		},
+		{
+			name:    "sensor types and unit",
+			opts:    []Option{WithSensorTypes("temperature"), WithTemperatureUnit("fahrenheit"), WithNamespace("acme")},
+			metrics: []string{"acme_ipmi_sensors_collected", "acme_ipmi_temperature_fahrenheit", "acme_ipmi_voltage_volts", "acme_ipmi_fan_speed_rpm"},
+			want: `
+# HELP acme_ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE acme_ipmi_sensors_collected gauge
+acme_ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 2
+# HELP acme_ipmi_temperature_fahrenheit IPMI temperature sensor readings in fahrenheit, converted from the celsius reported by the BMC
+# TYPE acme_ipmi_temperature_fahrenheit gauge
+acme_ipmi_temperature_fahrenheit{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 113
+acme_ipmi_temperature_fahrenheit{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 73.4
+`,
+		},
+		{
+			name:    "normalized names",
+			opts:    []Option{WithNormalizedNames()},
+			metrics: []string{"ipmi_temperature_celsius", "ipmi_fan_speed_rpm"},
+			want: `
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor="cpu_temp",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor="inlet_temp",sensor_id="04h",sensor_name="Inlet Temp"} 23
+# HELP ipmi_fan_speed_rpm IPMI fan speed sensor readings in RPM
+# TYPE ipmi_fan_speed_rpm gauge
+ipmi_fan_speed_rpm{fan_id="Fan1",host="bmc1",instance_name="bmc1",sensor="fan1_rpm",sensor_id="30h",sensor_name="Fan1 RPM"} 5400
+`,
+		},
+		{
+			name:    "kelvin",
+			opts:    []Option{WithTemperatureUnit("kelvin")},
+			metrics: []string{"ipmi_temperature_kelvin", "ipmi_temperature_celsius"},
+			want: `
+# HELP ipmi_temperature_kelvin IPMI temperature sensor readings in kelvin, converted from the celsius reported by the BMC
+# TYPE ipmi_temperature_kelvin gauge
+ipmi_temperature_kelvin{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 318.15
+ipmi_temperature_kelvin{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 296.15
+`,
+		},
+		{
+			name:    "voltage rails",
+			opts:    []Option{WithVoltageRails(3.3, 5, 12)},
+			metrics: []string{"ipmi_voltage_volts"},
+			want: `
+# HELP ipmi_voltage_volts IPMI voltage sensor readings in volts
+# TYPE ipmi_voltage_volts gauge
+ipmi_voltage_volts{host="bmc1",instance_name="bmc1",rail="12v",sensor_id="20h",sensor_name="12V"} 12.05
+`,
+		},
+		{
+			name:    "discrete states",
+			metrics: []string{"ipmi_sensor_state"},
+			want: `
+# HELP ipmi_sensor_state IPMI discrete sensor states, 1 if the state is asserted and 0 otherwise
+# TYPE ipmi_sensor_state gauge
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="ac_lost"} 1
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="ac_out_of_range"} 0
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="ac_out_of_range_present"} 0
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="config_error"} 0
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="failure_detected"} 0
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="predictive_failure"} 0
+ipmi_sensor_state{host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",state="presence_detected"} 1
+`,
+		},
+		{
+			name:    "series limit",
+			opts:    []Option{WithMaxSeries(5)},
+			metrics: []string{"ipmi_up", "ipmi_sensors_collected", "ipmi_series_dropped_total"},
+			want: `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="bmc1",instance_name="bmc1"} 1
+# HELP ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE ipmi_sensors_collected gauge
+ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 10
+# HELP ipmi_series_dropped_total Number of sensor series left out of scrapes for exceeding the per-host series limit
+# TYPE ipmi_series_dropped_total counter
+ipmi_series_dropped_total{host="bmc1",instance_name="bmc1"} 32
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := fixtureCollector(t, tt.fixtures, tt.opts...)
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(tt.want), tt.metrics...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNormalizeSensorName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"CPU1 Temp", "cpu1_temp"},
+		{"Inlet Temp", "inlet_temp"},
+		{"Fan-1A", "fan_1a"},
+		{"FAN 1 RPM", "fan_1_rpm"},
+		{"PS1 Input Power", "ps1_input_power"},
+		{"Temp_CPU0", "temp_cpu0"},
+		{"P1-DIMMA1 TEMP", "p1_dimma1_temp"},
+		{"CPU1 VCore (V)", "cpu1_vcore_v"},
+		{"Sys.Fan 2", "sys_fan_2"},
+		{"  12V  ", "12v"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSensorName(tt.name); got != tt.want {
+			t.Errorf("normalizeSensorName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectorFailedRefresh(t *testing.T) {
+	collector := NewCollector(IPMIConfig{Host: "bmc1"}, WithRunner(FileRunner{Path: "testdata/missing.txt"}))
+	if err := collector.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh() = nil, want an error")
+	}
+	want := `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="bmc1",instance_name="bmc1"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_up", "ipmi_temperature_celsius"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorSensorInfo(t *testing.T) {
+	// The entity label is the same whether WithEntityLabel adds it to
+	// every series or ipmi_sensor_info adds it on its own.
+	want := `
+# HELP ipmi_sensor_info A metric with a constant '1' value labeled by the status, unit and entity of the IPMI sensor as reported by ipmitool
+# TYPE ipmi_sensor_info gauge
+ipmi_sensor_info{entity="10.1",host="bmc1",instance_name="bmc1",sensor_id="70h",sensor_name="PS1 Input Power",status="ok",unit="watts"} 1
+ipmi_sensor_info{entity="10.1",host="bmc1",instance_name="bmc1",sensor_id="71h",sensor_name="PS1 Current",status="ok",unit="amperes"} 1
+ipmi_sensor_info{entity="10.1",host="bmc1",instance_name="bmc1",sensor_id="c8h",sensor_name="PS1 Status",status="ok",unit=""} 1
+ipmi_sensor_info{entity="29.1",host="bmc1",instance_name="bmc1",sensor_id="30h",sensor_name="Fan1 RPM",status="ok",unit="rpm"} 1
+ipmi_sensor_info{entity="29.1",host="bmc1",instance_name="bmc1",sensor_id="31h",sensor_name="Fan1 Duty",status="ok",unit="percent"} 1
+ipmi_sensor_info{entity="3.1",host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp",status="ok",unit="celsius"} 1
+ipmi_sensor_info{entity="4.3",host="bmc1",instance_name="bmc1",sensor_id="80h",sensor_name="Disk 3",status="ns",unit=""} 1
+ipmi_sensor_info{entity="7.1",host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp",status="ok",unit="celsius"} 1
+ipmi_sensor_info{entity="7.1",host="bmc1",instance_name="bmc1",sensor_id="20h",sensor_name="12V",status="ok",unit="volts"} 1
+ipmi_sensor_info{entity="7.1",host="bmc1",instance_name="bmc1",sensor_id="72h",sensor_name="Humidity",status="ok",unit="percent"} 1
+ipmi_sensor_info{entity="7.1",host="bmc1",instance_name="bmc1",sensor_id="d0h",sensor_name="OEM Raw",status="ok",unit=""} 1
+`
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"sensor info", []Option{WithSensorInfo()}},
+		{"with entity label", []Option{WithSensorInfo(), WithEntityLabel()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := fixtureCollector(t, nil, tt.opts...)
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_sensor_info"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if got := testutil.CollectAndCount(fixtureCollector(t, nil), "ipmi_sensor_info"); got != 0 {
+		t.Errorf("%d ipmi_sensor_info series without WithSensorInfo, want none", got)
+	}
+}
+
+func TestCollectorInstanceName(t *testing.T) {
+	config := IPMIConfig{Host: "10.0.0.10", InstanceName: "web-prod-01"}
+	collector := NewCollector(config, WithRunner(FileRunner{Path: "testdata/sdr_elist.txt"}))
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Every series carries the logical name next to the host, including the
+	// counters kept in Metrics.
+	want := `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="10.0.0.10",instance_name="web-prod-01"} 1
+# HELP ipmi_sensor_parse_errors_total Number of sdr lines that matched the sensor format but whose value could not be parsed
+# TYPE ipmi_sensor_parse_errors_total counter
+ipmi_sensor_parse_errors_total{host="10.0.0.10",instance_name="web-prod-01"} 1
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="10.0.0.10",instance_name="web-prod-01",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="10.0.0.10",instance_name="web-prod-01",sensor_id="04h",sensor_name="Inlet Temp"} 23
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_up", "ipmi_sensor_parse_errors_total", "ipmi_temperature_celsius"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorKeepOrClearOnError(t *testing.T) {
+	const up = `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="bmc1",instance_name="bmc1"} 0
+`
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "keep",
+			want: up + `# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 23
+`,
+		},
+		{name: "clear", opts: []Option{WithClearOnError()}, want: up},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fixtureRunner{path: "testdata/sdr_elist.txt"}
+			collector := NewCollector(IPMIConfig{Host: "bmc1"}, append([]Option{WithRunner(runner)}, tt.opts...)...)
+			if err := collector.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			runner.path = "testdata/missing.txt"
+			if err := collector.Refresh(context.Background()); err == nil {
+				t.Fatal("Refresh() = nil, want an error")
+			}
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(tt.want), "ipmi_up", "ipmi_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// partialRunner serves the sdr fixture but fails the command afterwards, like
+// a BMC that errors out over a single sensor after printing the others.
+type partialRunner struct{}
+
+// Run implements CommandRunner.
+func (partialRunner) Run(ctx context.Context, args []string, stdin string) ([]byte, []byte, error) {
+	stdout, _, err := FileRunner{Path: "testdata/sdr_elist.txt"}.Run(ctx, args, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stdout, []byte("Unable to read sensor 0xd1"), errors.New("exit status 1")
+}
+
+func TestCollectorPartialOutput(t *testing.T) {
+	collector := NewCollector(IPMIConfig{Host: "bmc1"}, WithRunner(partialRunner{}))
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() = %v, want the printed sensors used", err)
+	}
+	want := `
+# HELP ipmi_up Whether the last collection from the BMC was successful
+# TYPE ipmi_up gauge
+ipmi_up{host="bmc1",instance_name="bmc1"} 1
+# HELP ipmi_sensors_collected Number of sensors successfully parsed in the last collection
+# TYPE ipmi_sensors_collected gauge
+ipmi_sensors_collected{host="bmc1",instance_name="bmc1"} 10
+# HELP ipmi_command_errors_total Number of failed ipmitool commands by cause: auth, timeout, connection, unsupported or unknown
+# TYPE ipmi_command_errors_total counter
+ipmi_command_errors_total{host="bmc1",instance_name="bmc1",reason="unknown"} 1
+# HELP ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+# TYPE ipmi_temperature_celsius gauge
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45
+ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="04h",sensor_name="Inlet Temp"} 23
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_up", "ipmi_sensors_collected", "ipmi_command_errors_total", "ipmi_temperature_celsius"); err != nil {
+		t.Error(err)
+	}
+}
+
+// slowRunner serves the sdr fixture after delay, failing the first attempt
+// with a session error so that it is retried.
+type slowRunner struct {
+	delay time.Duration
+	calls int
+}
+
+// Run implements CommandRunner.
+func (r *slowRunner) Run(ctx context.Context, args []string, stdin string) ([]byte, []byte, error) {
+	time.Sleep(r.delay)
+	r.calls++
+	if r.calls == 1 {
+		return nil, []byte("Error: Unable to establish IPMI v2 / RMCP+ session"), errors.New("exit status 1")
+	}
+	return FileRunner{Path: "testdata/sdr_elist.txt"}.Run(ctx, args, stdin)
+}
+
+func TestCollectorSessionDuration(t *testing.T) {
+	runner := &slowRunner{delay: 20 * time.Millisecond}
+	collector := NewCollector(IPMIConfig{Host: "bmc1"}, WithRunner(runner), WithRetries(1))
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if runner.calls != 2 {
+		t.Fatalf("%d ipmitool runs, want the failed one retried", runner.calls)
+	}
+	// Both attempts count towards the session, the backoff between them
+	// only towards the scrape.
+	if session := collector.sessionDuration; session < 2*runner.delay || session >= retryBaseBackoff {
+		t.Errorf("session duration = %s, want both attempts of %s and no backoff", session, runner.delay)
+	}
+	if collector.duration < retryBaseBackoff+2*runner.delay {
+		t.Errorf("scrape duration = %s, want the backoff included", collector.duration)
+	}
+}
+
+// gatedRunner serves the sdr fixture once release is closed, signalling on
+// started as soon as a command runs.
+type gatedRunner struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+// Run implements CommandRunner.
+func (r gatedRunner) Run(ctx context.Context, args []string, stdin string) ([]byte, []byte, error) {
+	r.started <- struct{}{}
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	return FileRunner{Path: "testdata/sdr_elist.txt"}.Run(ctx, args, stdin)
+}
+
+func TestRefreshSkipsOverlappingCollection(t *testing.T) {
+	runner := gatedRunner{started: make(chan struct{}, 1), release: make(chan struct{})}
+	config := IPMIConfig{Host: "bmc1"}
+	first := NewCollector(config, WithRunner(runner))
+	// A second collector of the same BMC, like the one of an /ipmi scrape.
+	second := NewCollector(config, WithRunner(runner))
+
+	done := make(chan error)
+	go func() { done <- first.Refresh(context.Background()) }()
+	<-runner.started
+
+	for _, collector := range []*Collector{first, second} {
+		if err := collector.Refresh(context.Background()); !errors.Is(err, ErrCollectionInProgress) {
+			t.Errorf("overlapping Refresh() = %v, want ErrCollectionInProgress", err)
+		}
+	}
+	want := `
+# HELP ipmi_collection_skipped_total Number of collections skipped because the previous one from the same BMC was still running
+# TYPE ipmi_collection_skipped_total counter
+ipmi_collection_skipped_total{host="bmc1",instance_name="bmc1"} 1
+`
+	if err := testutil.CollectAndCompare(second, strings.NewReader(want), "ipmi_collection_skipped_total"); err != nil {
+		t.Error(err)
+	}
+
+	close(runner.release)
+	if err := <-done; err != nil {
+		t.Fatalf("first Refresh() = %v", err)
+	}
+	// The BMC is free again once the collection finished.
+	if err := second.Refresh(context.Background()); err != nil {
+		t.Errorf("Refresh() after the overlap = %v, want nil", err)
+	}
+}
+
+// latencyRunner simulates the cost of ipmitool talking to a BMC: every
+// command pays for setting up a session, and every sensor listed for reading
+// its record. sensors maps an `sdr type` name to the elist rows of that type.
+type latencyRunner struct {
+	sensors    map[string][]string
+	perCommand time.Duration
+	perSensor  time.Duration
+}
+
+// Run implements CommandRunner for `sdr elist full` and `sdr type`.
+func (r latencyRunner) Run(_ context.Context, args []string, _ string) ([]byte, []byte, error) {
+	var rows []string
+	switch i := slices.Index(args, "sdr"); {
+	case i >= 0 && len(args) > i+2 && args[i+1] == "type":
+		rows = r.sensors[args[i+2]]
+	case i >= 0:
+		for _, sdrType := range slices.Sorted(maps.Keys(r.sensors)) {
+			rows = append(rows, r.sensors[sdrType]...)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported command %v", args)
+	}
+	time.Sleep(r.perCommand + time.Duration(len(rows))*r.perSensor)
+	return []byte(strings.Join(rows, "\n") + "\n"), nil, nil
+}
+
+// benchmarkSensors returns the sensors of a large server by `sdr type` name:
+// 120 in all, of which 56 are temperatures and fans.
+func benchmarkSensors() map[string][]string {
+	kinds := []struct {
+		sdrType, row string
+		count        int
+	}{
+		{"Temperature", "Temp%d | %02Xh | ok | 3.1 | 45 degrees C", 40},
+		{"Fan", "Fan%d | %02Xh | ok | 29.1 | 5400 RPM", 16},
+		{"Voltage", "P12V_%d | %02Xh | ok | 7.1 | 12.05 Volts", 24},
+		{"Current", "PS%d Current | %02Xh | ok | 10.1 | 1.2 Amps", 8},
+		{"Power Supply", "PS%d Status | %02Xh | ok | 10.1 | Presence detected", 32},
+	}
+	sensors := make(map[string][]string)
+	id := 0
+	for _, kind := range kinds {
+		for i := range kind.count {
+			sensors[kind.sdrType] = append(sensors[kind.sdrType], fmt.Sprintf(kind.row, i, id))
+			id++
+		}
+	}
+	return sensors
+}
+
+// BenchmarkSDRQueries compares listing all sensors with `sdr elist` against
+// one `sdr type` per selected type under WithSDRTypeQueries, with temperature
+// and fan selected. The BMC is simulated at a hundredth of the latency of a
+// typical lanplus session: 3ms per command and 150µs per sensor read.
+func BenchmarkSDRQueries(b *testing.B) {
+	runner := latencyRunner{
+		sensors:    benchmarkSensors(),
+		perCommand: 3 * time.Millisecond,
+		perSensor:  150 * time.Microsecond,
+	}
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"elist", nil},
+		{"type", []Option{WithSDRTypeQueries()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			opts := append([]Option{WithRunner(runner), WithSensorTypes("temperature", "fan")}, bench.opts...)
+			collector := NewCollector(IPMIConfig{Host: "bench-" + bench.name, Interface: "open"}, opts...)
+			for b.Loop() {
+				if err := collector.Refresh(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
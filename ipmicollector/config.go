@@ -0,0 +1,77 @@
+package ipmicollector
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is the BMC port used for configs that do not set one.
+const DefaultPort = 623
+
+// DefaultInterface is the ipmitool interface used for configs that do not
+// set one.
+const DefaultInterface = "lanplus"
+
+// IPMIConfig describes how to reach a BMC.
+type IPMIConfig struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Port     int    `yaml:"port"`
+	// Interface is the ipmitool interface: lan, lanplus or open. With open
+	// the local BMC is used and Host only names the target in labels.
+	Interface string `yaml:"interface"`
+	// CipherSuite is passed to ipmitool as -C when set.
+	CipherSuite string `yaml:"cipher_suite"`
+	// OEM is passed to ipmitool as -o when set, e.g. "supermicro".
+	OEM string `yaml:"oem"`
+	// InstanceName is a logical name for the server, such as "web-prod-01",
+	// exported as the instance_name label. It defaults to Host.
+	InstanceName string `yaml:"instance_name"`
+}
+
+// Address returns the host:port of the BMC, with IPv6 hosts bracketed.
+func (c IPMIConfig) Address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// withDefaults returns c with the host normalized and the port, interface
+// and instance name filled in if unset.
+func (c IPMIConfig) withDefaults() IPMIConfig {
+	c.Host = NormalizeHost(c.Host)
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+	if c.Interface == "" {
+		c.Interface = DefaultInterface
+	}
+	if c.InstanceName == "" {
+		c.InstanceName = c.Host
+	}
+	return c
+}
+
+// NormalizeHost strips the brackets from an IPv6 literal such as
+// "[fe80::1%eth0]" and returns IPv6 addresses in canonical form, since
+// ipmitool expects a bare address for -H. Other hosts are returned as is.
+func NormalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	bare := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if addr, err := netip.ParseAddr(bare); err == nil && addr.Is6() && !addr.Is4In6() {
+		return addr.String()
+	}
+	return host
+}
+
+// ValidateHost checks that host, as returned by NormalizeHost, names a BMC
+// without a port, such as "bmc1:623" or "[fe80::1]:623", which would
+// otherwise end up in the -H of ipmitool. The port is set separately.
+func ValidateHost(host string) error {
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return fmt.Errorf("host %q must not include a port, set the port to %s separately", host, port)
+	}
+	return nil
+}
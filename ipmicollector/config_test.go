@@ -0,0 +1,62 @@
+package ipmicollector
+
+import "testing"
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"bmc1.example.com", "bmc1.example.com"},
+		{" 10.0.0.10 ", "10.0.0.10"},
+		{"::1", "::1"},
+		{"[::1]", "::1"},
+		{"[2001:DB8:0:0::1]", "2001:db8::1"},
+		{"[fe80::1%eth0]", "fe80::1%eth0"},
+		// IPv4-mapped addresses are left for ipmitool to resolve as given.
+		{"::ffff:10.0.0.10", "::ffff:10.0.0.10"},
+		// A host with a port is not an address, and ValidateHost rejects it.
+		{"[::1]:623", "[::1]:623"},
+		{"bmc1:623", "bmc1:623"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeHost(tt.host); got != tt.want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"bmc1.example.com", false},
+		{"10.0.0.10", false},
+		{"::1", false},
+		{"fe80::1%eth0", false},
+		{"[::1]:623", true},
+		{"bmc1:623", true},
+		{"10.0.0.10:623", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateHost(NormalizeHost(tt.host)); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateHost(%q) = %v, want error %v", tt.host, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIPMIConfigAddress(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"bmc1", "bmc1:623"},
+		{"::1", "[::1]:623"},
+		{"[::1]", "[::1]:623"},
+	}
+	for _, tt := range tests {
+		config := IPMIConfig{Host: tt.host}.withDefaults()
+		if got := config.Address(); got != tt.want {
+			t.Errorf("Address() of %q = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ipmicollector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dcmiPowerFields maps the labels printed by `ipmitool dcmi power reading` to
+// the type label exported in ipmi_dcmi_power_watts.
+var dcmiPowerFields = map[string]string{
+	"instantaneous power reading":              "instantaneous",
+	"minimum during sampling period":           "minimum",
+	"maximum during sampling period":           "maximum",
+	"average power reading over sample period": "average",
+}
+
+// dcmiPower holds the DCMI power readings of a BMC.
+type dcmiPower struct {
+	// Readings maps a reading type such as "instantaneous" to its value in
+	// watts.
+	Readings map[string]float64
+	// Period is the sampling period the BMC reports the readings over, such
+	// as "5s", or empty if it reports none.
+	Period string
+}
+
+// dcmiPeriodPattern matches DCMI averaging periods, which are passed through
+// to ipmitool, such as "5_min".
+var dcmiPeriodPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ValidateDCMIPeriod checks that period is empty or well-formed for WithDCMI.
+func ValidateDCMIPeriod(period string) error {
+	if period == "" || dcmiPeriodPattern.MatchString(period) {
+		return nil
+	}
+	return fmt.Errorf("invalid DCMI period %q: must consist of letters, digits and underscores", period)
+}
+
+// collectDCMIPower returns the DCMI power readings of the BMC, averaged over
+// the WithDCMI period if set. BMCs that reject the period are queried again
+// without it, and BMCs without DCMI support yield no readings and no error.
+func (c *Collector) collectDCMIPower(ctx context.Context) (*dcmiPower, error) {
+	period := c.opts.dcmiPeriod
+	args := []string{"dcmi", "power", "reading"}
+	if period != "" {
+		args = append(args, period)
+	}
+
+	output, err := c.executeIPMICommand(ctx, args...)
+	if err != nil && period != "" && ctx.Err() == nil {
+		slog.Debug("DCMI power reading with period failed, retrying without", "host", c.config.Host, "period", period, "err", err)
+		output, err = c.executeIPMICommand(ctx, "dcmi", "power", "reading")
+	}
+	if err != nil {
+		if isUnsupportedError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseDCMIPowerReading(output), nil
+}
+
+// parseDCMIPowerReading parses lines such as
+// "Instantaneous power reading:   220 Watts" and
+// "Sampling period:   00000005 Seconds.".
+func parseDCMIPowerReading(output string) *dcmiPower {
+	power := &dcmiPower{Readings: make(map[string]float64)}
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if key == "sampling period" {
+			power.Period = parseDCMIPeriod(fields)
+			continue
+		}
+		readingType, ok := dcmiPowerFields[key]
+		if !ok {
+			continue
+		}
+		if watts, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			power.Readings[readingType] = watts
+		}
+	}
+
+	return power
+}
+
+// parseDCMIPeriod turns the fields of a sampling period such as
+// "00000005 Seconds." into "5s", keeping other units as printed.
+func parseDCMIPeriod(fields []string) string {
+	seconds, err := strconv.Atoi(fields[0])
+	if err != nil || len(fields) < 2 || !strings.HasPrefix(strings.ToLower(fields[1]), "second") {
+		return strings.TrimSuffix(strings.Join(fields, " "), ".")
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// isUnsupportedError reports whether ipmitool failed because the BMC does not
+// implement the requested command.
+func isUnsupportedError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not supported") || strings.Contains(msg, "invalid command")
+}
@@ -0,0 +1,100 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseDCMIPowerReading(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *dcmiPower
+	}{
+		{
+			name: "full reading",
+			output: `
+    Instantaneous power reading:                   220 Watts
+    Minimum during sampling period:                 90 Watts
+    Maximum during sampling period:                410 Watts
+    Average power reading over sample period:      205 Watts
+    IPMI timestamp:                           Thu Jan  1 00:19:46 1970
+    Sampling period:                          00000005 Seconds.
+    Power reading state is:                   activated
+`,
+			want: &dcmiPower{
+				Readings: map[string]float64{"instantaneous": 220, "minimum": 90, "maximum": 410, "average": 205},
+				Period:   "5s",
+			},
+		},
+		{
+			name:   "period in minutes",
+			output: "Instantaneous power reading: 220 Watts\nSampling period: 00000005 Minutes.\n",
+			want:   &dcmiPower{Readings: map[string]float64{"instantaneous": 220}, Period: "00000005 Minutes"},
+		},
+		{
+			name:   "no readings",
+			output: "Power reading state is: deactivated\nInstantaneous power reading: unavailable\n",
+			want:   &dcmiPower{Readings: map[string]float64{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDCMIPowerReading(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDCMIPowerReading() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDCMIPeriod(t *testing.T) {
+	for _, period := range []string{"", "5_sec", "1_hour"} {
+		if err := ValidateDCMIPeriod(period); err != nil {
+			t.Errorf("ValidateDCMIPeriod(%q) = %v, want nil", period, err)
+		}
+	}
+	for _, period := range []string{"5 sec", "-i", "5;reboot"} {
+		if err := ValidateDCMIPeriod(period); err == nil {
+			t.Errorf("ValidateDCMIPeriod(%q) = nil, want an error", period)
+		}
+	}
+}
+
+func TestIsUnsupportedError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("DCMI request failed because: Invalid command (c1)"), true},
+		{errors.New("Get Chassis Power Status failed: Command not supported in present state"), true},
+		{errors.New("Unable to establish IPMI v2 / RMCP+ session"), false},
+	}
+	for _, tt := range tests {
+		if got := isUnsupportedError(tt.err); got != tt.want {
+			t.Errorf("isUnsupportedError(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("Error: Unable to establish IPMI v2 / RMCP+ session: RAKP 2 HMAC is invalid"), "auth"},
+		{errors.New("Activate Session error:\tInvalid user name"), "auth"},
+		{fmt.Errorf("ipmitool command for bmc1:623 timed out after 10s: %w", context.DeadlineExceeded), "timeout"},
+		{errors.New("Error: Unable to establish IPMI v2 / RMCP+ session"), "connection"},
+		{errors.New("failed to connect to ssh relay jump:22: connection refused"), "connection"},
+		{errors.New("DCMI request failed because: Invalid command (c1)"), "unsupported"},
+		{errors.New("failed to execute ipmitool command: exit status 1"), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%q) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
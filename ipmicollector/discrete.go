@@ -0,0 +1,155 @@
+package ipmicollector
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// discreteSensorType describes a family of discrete sensors whose reading is
+// a list of asserted states rather than a number. Sensors are matched by name,
+// and every known state is exported with 1 when asserted and 0 otherwise.
+type discreteSensorType struct {
+	name   string
+	match  *regexp.Regexp
+	states map[string]string // lowercase reading text -> state label
+}
+
+// discreteSensorTypes lists the discrete sensors the exporter understands. To
+// support another sensor family, add an entry with its name pattern and the
+// reading texts ipmitool prints for it.
+var discreteSensorTypes = []discreteSensorType{
+	{
+		name:  "power_supply",
+		match: regexp.MustCompile(`(?i)^(PS\d*|PSU\d*|Power Supply\s*\d*)\b`),
+		states: map[string]string{
+			"presence detected":            "presence_detected",
+			"failure detected":             "failure_detected",
+			"predictive failure":           "predictive_failure",
+			"power supply ac lost":         "ac_lost",
+			"ac lost or out-of-range":      "ac_out_of_range",
+			"ac out-of-range, but present": "ac_out_of_range_present",
+			"config error":                 "config_error",
+		},
+	},
+	{
+		name:  "chassis_intrusion",
+		match: regexp.MustCompile(`(?i)intru`),
+		states: map[string]string{
+			"general chassis intrusion": "general_chassis_intrusion",
+			"drive bay intrusion":       "drive_bay_intrusion",
+			"i/o card area intrusion":   "io_card_area_intrusion",
+			"processor area intrusion":  "processor_area_intrusion",
+			"system unplugged from lan": "lan_unplugged",
+			"unauthorized dock":         "unauthorized_dock",
+			"fan area intrusion":        "fan_area_intrusion",
+		},
+	},
+	{
+		// The POST and boot progress of the system firmware (sensor type
+		// 0Fh), exported as ipmi_system_firmware_progress. ipmitool prints
+		// the progress or error code when the BMC provides it and the
+		// generic event otherwise.
+		name:  "system_firmware_progress",
+		match: regexp.MustCompile(`(?i)(firmware|fw|post|bios)\s*progress`),
+		states: map[string]string{
+			"system firmware error":                   "firmware_error",
+			"system firmware hang":                    "firmware_hang",
+			"system firmware progress":                "firmware_progress",
+			"unspecified":                             "unspecified",
+			"memory initialization":                   "memory_initialization",
+			"hard-disk initialization":                "hard_disk_initialization",
+			"secondary cpu initialization":            "secondary_cpu_initialization",
+			"user authentication":                     "user_authentication",
+			"user-initiated system setup":             "user_initiated_system_setup",
+			"usb resource configuration":              "usb_resource_configuration",
+			"pci resource configuration":              "pci_resource_configuration",
+			"option rom initialization":               "option_rom_initialization",
+			"video initialization":                    "video_initialization",
+			"cache initialization":                    "cache_initialization",
+			"smbus initialization":                    "smbus_initialization",
+			"keyboard controller initialization":      "keyboard_controller_initialization",
+			"management controller initialization":    "management_controller_initialization",
+			"calling operating system wake-up vector": "os_wake_up_vector",
+			"system boot initiated":                   "system_boot_initiated",
+			"motherboard initialization":              "motherboard_initialization",
+			"primary cpu initialization":              "primary_cpu_initialization",
+			"no system memory installed":              "no_memory_installed",
+			"no usable system memory":                 "no_usable_memory",
+			"unrecoverable system-board failure":      "system_board_failure",
+			"removable boot media not found":          "boot_media_not_found",
+			"unrecoverable video controller failure":  "video_controller_failure",
+			"no video device selected":                "no_video_device",
+			"bios corruption detected":                "bios_corruption",
+			"cpu voltage mismatch":                    "cpu_voltage_mismatch",
+			"cpu speed mismatch failure":              "cpu_speed_mismatch",
+		},
+	},
+}
+
+// discreteStatePattern returns a case-insensitive regexp alternation of every
+// known discrete reading text, longest first.
+func discreteStatePattern() string {
+	var texts []string
+	for _, t := range discreteSensorTypes {
+		for text := range t.states {
+			texts = append(texts, regexp.QuoteMeta(text))
+		}
+	}
+	slices.SortFunc(texts, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	return "(?i:" + strings.Join(texts, "|") + ")"
+}
+
+// lookupDiscreteSensorType returns the discrete sensor family called name.
+func lookupDiscreteSensorType(name string) (discreteSensorType, bool) {
+	for _, t := range discreteSensorTypes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return discreteSensorType{}, false
+}
+
+// parseDiscreteState matches a sensor against the known discrete sensor
+// families and returns the family name together with the asserted states.
+func parseDiscreteState(sensorName, valueStr string) (string, []string, bool) {
+	for _, t := range discreteSensorTypes {
+		if !t.match.MatchString(sensorName) {
+			continue
+		}
+
+		// Some state texts contain commas themselves, so try the whole
+		// reading before treating it as a comma-separated list.
+		var asserted []string
+		if state, ok := t.states[strings.ToLower(valueStr)]; ok {
+			asserted = append(asserted, state)
+		} else {
+			for _, part := range strings.Split(valueStr, ",") {
+				if state, ok := t.states[strings.ToLower(strings.TrimSpace(part))]; ok {
+					asserted = append(asserted, state)
+				}
+			}
+		}
+		if len(asserted) == 0 {
+			return "", nil, false
+		}
+		return t.name, asserted, true
+	}
+	return "", nil, false
+}
+
+// stateLabels returns the distinct state labels known for the family.
+func (t discreteSensorType) stateLabels() []string {
+	seen := make(map[string]bool, len(t.states))
+	var labels []string
+	for _, label := range t.states {
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,53 @@
+package ipmicollector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseDiscreteStateFirmwareProgress(t *testing.T) {
+	tests := []struct {
+		sensor, reading string
+		want            []string
+	}{
+		{"System FW Progress", "System boot initiated", []string{"system_boot_initiated"}},
+		{"POST Progress", "Memory initialization", []string{"memory_initialization"}},
+		{"BIOS Progress", "Calling operating system wake-up vector", []string{"os_wake_up_vector"}},
+		{"Firmware Progress", "USB resource configuration, Video initialization", []string{"usb_resource_configuration", "video_initialization"}},
+		{"System FW Progress", "Unspecified", []string{"unspecified"}},
+		{"POST Progress", "No system memory installed", []string{"no_memory_installed"}},
+	}
+	for _, tt := range tests {
+		sensorType, states, ok := parseDiscreteState(tt.sensor, tt.reading)
+		if !ok || sensorType != "system_firmware_progress" || !reflect.DeepEqual(states, tt.want) {
+			t.Errorf("parseDiscreteState(%q, %q) = %q, %q, %v, want system_firmware_progress, %q, true", tt.sensor, tt.reading, sensorType, states, ok, tt.want)
+		}
+	}
+
+	if _, _, ok := parseDiscreteState("System FW Progress", "Progress code 0x1f"); ok {
+		t.Error("parseDiscreteState() matched an unknown progress code")
+	}
+}
+
+func TestCollectorFirmwareProgress(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "sdr_elist.txt")
+	output := "System FW Progress | 0Fh | ok  | 34.1 | System boot initiated\n"
+	if err := os.WriteFile(fixture, []byte(output), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	collector := fixtureCollector(t, []string{fixture})
+	// Only the asserted state is exported, not one series per known state.
+	want := `
+# HELP ipmi_system_firmware_progress POST and boot progress of the system firmware, 1 for each state asserted by the IPMI sensor, such as system_boot_initiated
+# TYPE ipmi_system_firmware_progress gauge
+ipmi_system_firmware_progress{host="bmc1",instance_name="bmc1",sensor_id="0Fh",sensor_name="System FW Progress",state="system_boot_initiated"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_system_firmware_progress"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package ipmicollector reads sensors from a BMC with ipmitool and exposes
+// them as Prometheus metrics. It is what the ipmi-prometheus-exporter binary
+// is built on, and can be embedded into other exporters.
+//
+// A Collector serves the readings of its last Refresh, so scrapes never wait
+// on the BMC and the caller decides how often it is polled. To add a BMC to
+// an existing registry:
+//
+//	collector := ipmicollector.NewCollector(ipmicollector.IPMIConfig{
+//		Host:     "10.0.0.10",
+//		Username: "admin",
+//		Password: os.Getenv("BMC_PASSWORD"),
+//	}, ipmicollector.WithThresholds(), ipmicollector.WithNamespace("acme"))
+//	registry.MustRegister(collector)
+//
+//	go func() {
+//		ticker := time.NewTicker(30 * time.Second)
+//		defer ticker.Stop()
+//		for {
+//			// Failures are reported on the registry as acme_ipmi_up 0.
+//			_ = collector.Refresh(ctx)
+//			select {
+//			case <-ctx.Done():
+//				return
+//			case <-ticker.C:
+//			}
+//		}
+//	}()
+//
+// ExampleNewCollector shows a complete collection from captured ipmitool
+// output with a FileRunner.
+//
+// Collectors for several BMCs can share a registry, since every metric
+// carries the host as a label. To export the collectors' own counters once
+// for all of them, create a Metrics, register it and pass it to each
+// Collector with WithMetrics.
+package ipmicollector
@@ -0,0 +1,82 @@
+package ipmicollector
+
+import "time"
+
+// energyCounter turns the running energy total of a sensor into a counter
+// that survives the BMC resetting the total.
+type energyCounter struct {
+	// last is the last reading of the sensor.
+	last float64
+	// offset is the sum of the totals reached before each reset.
+	offset float64
+}
+
+// value returns the counter value: the current reading plus the totals lost
+// to resets.
+func (e energyCounter) value() float64 {
+	return e.offset + e.last
+}
+
+// energyResetRatio is how far below the last one a reading has to drop to be
+// taken as the BMC resetting the total. A total restarts from about zero,
+// while a BMC correcting or rounding its total lowers it by a little.
+const energyResetRatio = 0.5
+
+// trackEnergy returns the counters of the energy sensors in sensors, carried
+// over from previous. A reading under half the last one is taken as the BMC
+// having reset the total, typically by rebooting, so the last total is added
+// to the offset and the counter keeps increasing. Smaller decreases are
+// ignored, holding the counter until the reading passes the last one again.
+// As with trackChanges, sensors no longer reported are forgotten.
+func trackEnergy(previous map[changeKey]energyCounter, sensors []SensorData) map[changeKey]energyCounter {
+	counters := make(map[changeKey]energyCounter)
+	for _, sensor := range sensors {
+		if sensor.Type != "energy" || sensor.NoReading {
+			continue
+		}
+		key := changeKey{sensor.Name, sensor.ID}
+		counter := previous[key]
+		switch {
+		case sensor.Value < counter.last*energyResetRatio:
+			counter.offset += counter.last
+			counter.last = sensor.Value
+		case sensor.Value > counter.last:
+			counter.last = sensor.Value
+		}
+		counters[key] = counter
+	}
+	return counters
+}
+
+// dcmiEnergy is the energy consumed by a system as estimated from its DCMI
+// power readings, which unlike energy sensors carry no running total.
+type dcmiEnergy struct {
+	// joules is the energy consumed since the first reading.
+	joules float64
+	// watts is the last power reading, taken at at.
+	watts float64
+	at    time.Time
+}
+
+// integrateDCMIEnergy returns previous with the energy consumed up to now
+// added, assuming the power changed linearly from the last reading to power.
+// It uses the average over the sampling period where the BMC reports one,
+// and the instantaneous reading otherwise. previous is nil until the first
+// reading, which starts the total at zero.
+func integrateDCMIEnergy(previous *dcmiEnergy, power *dcmiPower, now time.Time) *dcmiEnergy {
+	watts, ok := power.Readings["average"]
+	if !ok {
+		watts, ok = power.Readings["instantaneous"]
+	}
+	if !ok {
+		return previous
+	}
+	if previous == nil {
+		return &dcmiEnergy{watts: watts, at: now}
+	}
+	next := dcmiEnergy{joules: previous.joules, watts: watts, at: now}
+	if elapsed := now.Sub(previous.at).Seconds(); elapsed > 0 {
+		next.joules += (previous.watts + watts) / 2 * elapsed
+	}
+	return &next
+}
@@ -0,0 +1,75 @@
+package ipmicollector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackEnergy(t *testing.T) {
+	tests := []struct {
+		name     string
+		readings []float64
+		want     []float64
+	}{
+		{name: "increasing", readings: []float64{1000, 1500, 2500}, want: []float64{1000, 1500, 2500}},
+		{name: "reset", readings: []float64{1000, 4000, 200, 700}, want: []float64{1000, 4000, 4200, 4700}},
+		{name: "repeated resets", readings: []float64{3000, 100, 2000, 0, 50}, want: []float64{3000, 3100, 5000, 5000, 5050}},
+		// A BMC correcting its total down a little does not make the
+		// counter go back or jump up by a whole total.
+		{name: "small decrease", readings: []float64{4000, 3990, 4100}, want: []float64{4000, 4000, 4100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var counters map[changeKey]energyCounter
+			key := changeKey{"PS1 Energy", "75h"}
+			for i, reading := range tt.readings {
+				sensors := []SensorData{{Name: "PS1 Energy", ID: "75h", Type: "energy", Value: reading}}
+				counters = trackEnergy(counters, sensors)
+				if got := counters[key].value(); got != tt.want[i] {
+					t.Errorf("reading %d (%v): counter = %v, want %v", i, reading, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrackEnergyIgnoresOtherSensors(t *testing.T) {
+	sensors := []SensorData{
+		{Name: "PS1 Input Power", ID: "70h", Type: "power", Value: 220},
+		{Name: "PS1 Energy", ID: "75h", Type: "energy", NoReading: true},
+	}
+	if counters := trackEnergy(nil, sensors); len(counters) != 0 {
+		t.Errorf("trackEnergy() = %v, want no counters", counters)
+	}
+}
+
+func TestIntegrateDCMIEnergy(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	readings := []struct {
+		after time.Duration
+		power map[string]float64
+		want  float64
+	}{
+		{0, map[string]float64{"instantaneous": 200}, 0},
+		// 200W for 30s.
+		{30 * time.Second, map[string]float64{"instantaneous": 200}, 6000},
+		// Power rising linearly from 200W to 300W over 60s.
+		{90 * time.Second, map[string]float64{"instantaneous": 300}, 21000},
+		// The average over the sampling period is preferred.
+		{120 * time.Second, map[string]float64{"instantaneous": 900, "average": 300}, 30000},
+		// A reading without power values leaves the total as it was.
+		{150 * time.Second, map[string]float64{}, 30000},
+		{180 * time.Second, map[string]float64{"average": 300}, 48000},
+	}
+	var energy *dcmiEnergy
+	for i, reading := range readings {
+		energy = integrateDCMIEnergy(energy, &dcmiPower{Readings: reading.power}, start.Add(reading.after))
+		if energy == nil || energy.joules != reading.want {
+			t.Fatalf("reading %d: energy = %+v, want %v joules", i, energy, reading.want)
+		}
+	}
+
+	if got := integrateDCMIEnergy(nil, &dcmiPower{Readings: map[string]float64{}}, start); got != nil {
+		t.Errorf("integrateDCMIEnergy() without power readings = %+v, want nil", got)
+	}
+}
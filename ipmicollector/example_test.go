@@ -0,0 +1,65 @@
+package ipmicollector_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+func ExampleNewCollector() {
+	// A FileRunner serves captured `ipmitool sdr elist full` output in place
+	// of a BMC.
+	dir, err := os.MkdirTemp("", "ipmicollector")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sdr := filepath.Join(dir, "sdr.txt")
+	output := "CPU Temp         | 01h | ok  |  3.1 | 45 degrees C\n" +
+		"Fan1             | 30h | ok  | 29.1 | 5400 RPM\n"
+	if err := os.WriteFile(sdr, []byte(output), 0o600); err != nil {
+		log.Fatal(err)
+	}
+
+	collector := ipmicollector.NewCollector(
+		ipmicollector.IPMIConfig{Host: "10.0.0.10", InstanceName: "web-prod-01"},
+		ipmicollector.WithRunner(ipmicollector.FileRunner{Path: sdr}),
+		ipmicollector.WithNamespace("acme"),
+	)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	// Refresh is usually called from a loop; failures are reported on the
+	// registry as acme_ipmi_up 0.
+	if err := collector.Refresh(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, family := range families {
+		switch family.GetName() {
+		case "acme_ipmi_up", "acme_ipmi_temperature_celsius", "acme_ipmi_fan_speed_rpm":
+			if _, err := expfmt.MetricFamilyToText(os.Stdout, family); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// Output:
+	// # HELP acme_ipmi_fan_speed_rpm IPMI fan speed sensor readings in RPM
+	// # TYPE acme_ipmi_fan_speed_rpm gauge
+	// acme_ipmi_fan_speed_rpm{fan_id="Fan1",host="10.0.0.10",instance_name="web-prod-01",sensor_id="30h",sensor_name="Fan1"} 5400
+	// # HELP acme_ipmi_temperature_celsius IPMI temperature sensor readings in celsius
+	// # TYPE acme_ipmi_temperature_celsius gauge
+	// acme_ipmi_temperature_celsius{host="10.0.0.10",instance_name="web-prod-01",sensor_id="01h",sensor_name="CPU Temp"} 45
+	// # HELP acme_ipmi_up Whether the last collection from the BMC was successful
+	// # TYPE acme_ipmi_up gauge
+	// acme_ipmi_up{host="10.0.0.10",instance_name="web-prod-01"} 1
+}
@@ -0,0 +1,121 @@
+package ipmicollector
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SensorFilter selects sensors by name. The zero value allows every sensor.
+type SensorFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// NewSensorFilter returns a filter allowing the sensors whose name matches
+// the regular expression include and not exclude. An empty include allows
+// every sensor and an empty exclude drops none.
+func NewSensorFilter(include, exclude string) (SensorFilter, error) {
+	var (
+		filter SensorFilter
+		err    error
+	)
+	if include != "" {
+		if filter.include, err = regexp.Compile(include); err != nil {
+			return SensorFilter{}, fmt.Errorf("invalid include pattern: %v", err)
+		}
+	}
+	if exclude != "" {
+		if filter.exclude, err = regexp.Compile(exclude); err != nil {
+			return SensorFilter{}, fmt.Errorf("invalid exclude pattern: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// allows reports whether the sensor called name should be collected. Exclude
+// wins over include.
+func (f SensorFilter) allows(name string) bool {
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return f.include == nil || f.include.MatchString(name)
+}
+
+// sensorCategories lists the sensor types accepted by WithSensorTypes.
+var sensorCategories = []string{"voltage", "temperature", "fan", "power", "current", "percent", "airflow", "humidity", "energy", "raw", "discrete"}
+
+// ParseSensorTypes parses a comma-separated list of sensor types for
+// WithSensorTypes, out of voltage, temperature, fan, power, current,
+// percent, airflow, humidity, energy, raw and discrete. An empty list yields
+// nil.
+func ParseSensorTypes(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var types []string
+	for _, category := range strings.Split(list, ",") {
+		category = strings.TrimSpace(category)
+		if !slices.Contains(sensorCategories, category) {
+			return nil, fmt.Errorf("invalid sensor type %q: must be one of %s", category, strings.Join(sensorCategories, ", "))
+		}
+		types = append(types, category)
+	}
+	return types, nil
+}
+
+// sdrTypes maps the WithSensorTypes categories that correspond to an IPMI
+// sensor type to the name `ipmitool sdr type` takes for it. The others, such
+// as power, are spread over several IPMI sensor types.
+var sdrTypes = map[string]string{
+	"voltage":     "Voltage",
+	"temperature": "Temperature",
+	"fan":         "Fan",
+	"current":     "Current",
+}
+
+// ValidateSDRTypes checks that every sensor type, as returned by
+// ParseSensorTypes, can be queried with WithSDRTypeQueries.
+func ValidateSDRTypes(types []string) error {
+	if len(types) == 0 {
+		return fmt.Errorf("no sensor types to query")
+	}
+	for _, category := range types {
+		if _, ok := sdrTypes[category]; !ok {
+			return fmt.Errorf("sensor type %q can't be queried with sdr type: only %s can", category, strings.Join(slices.Sorted(maps.Keys(sdrTypes)), ", "))
+		}
+	}
+	return nil
+}
+
+// sdrTypeQueries returns the ipmitool sensor types to query for the selected
+// categories in a stable order, or nil if any of them has none, in which case
+// all sensors have to be listed.
+func sdrTypeQueries(types map[string]bool) []string {
+	if len(types) == 0 {
+		return nil
+	}
+	var queries []string
+	for category := range types {
+		sdrType, ok := sdrTypes[category]
+		if !ok {
+			return nil
+		}
+		queries = append(queries, sdrType)
+	}
+	slices.Sort(queries)
+	return queries
+}
+
+// sensorCategory returns the WithSensorTypes category of a sensor type.
+func sensorCategory(sensorType string) string {
+	if sensorType == "fan_percent" || sensorType == "fan_redundancy" {
+		return "fan"
+	}
+	if _, ok := lookupDiscreteSensorType(sensorType); ok {
+		return "discrete"
+	}
+	return sensorType
+}
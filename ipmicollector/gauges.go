@@ -0,0 +1,159 @@
+package ipmicollector
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sensorGauge describes the gauge a numeric sensor type is exported as and,
+// for types BMCs report thresholds for, the gauge of its thresholds.
+type sensorGauge struct {
+	sensorType string
+	name       string
+	help       string
+	// thresholdName is empty for types without thresholds.
+	thresholdName string
+	thresholdHelp string
+	// label is an extra label further identifying the sensor, filled in by
+	// gaugeLabelValue. It is not added to the thresholds.
+	label string
+}
+
+// sensorGauges returns the gauges of the numeric sensor types as configured
+// by o. Adding a unit to valueUnits takes an entry here to be exported.
+func sensorGauges(o options) []sensorGauge {
+	tempUnit := o.temperatureUnit.name
+	tempHelp := "in " + tempUnit
+	if tempUnit != "celsius" {
+		tempHelp += ", converted from the celsius reported by the BMC"
+	}
+	var railLabel string
+	if len(o.rails) > 0 {
+		railLabel = "rail"
+	}
+
+	return []sensorGauge{
+		{
+			sensorType:    "voltage",
+			name:          "ipmi_voltage_volts",
+			help:          "IPMI voltage sensor readings in volts",
+			thresholdName: "ipmi_voltage_threshold_volts",
+			thresholdHelp: "IPMI voltage sensor thresholds in volts",
+			label:         railLabel,
+		},
+		{
+			sensorType:    "temperature",
+			name:          "ipmi_temperature_" + tempUnit,
+			help:          "IPMI temperature sensor readings " + tempHelp,
+			thresholdName: "ipmi_temperature_threshold_" + tempUnit,
+			thresholdHelp: "IPMI temperature sensor thresholds " + tempHelp,
+		},
+		{
+			sensorType:    "fan",
+			name:          "ipmi_fan_speed_rpm",
+			help:          "IPMI fan speed sensor readings in RPM",
+			thresholdName: "ipmi_fan_speed_threshold_rpm",
+			thresholdHelp: "IPMI fan speed sensor thresholds in RPM",
+			label:         "fan_id",
+		},
+		{
+			sensorType: "fan_percent",
+			name:       "ipmi_fan_speed_percent",
+			help:       "IPMI fan speed sensor readings in percent of maximum",
+			label:      "fan_id",
+		},
+		{
+			sensorType:    "power",
+			name:          "ipmi_power_watts",
+			help:          "IPMI power sensor readings in watts, with direction input or output for power supply sensors",
+			thresholdName: "ipmi_power_threshold_watts",
+			thresholdHelp: "IPMI power sensor thresholds in watts",
+			label:         "direction",
+		},
+		{
+			sensorType:    "current",
+			name:          "ipmi_current_amperes",
+			help:          "IPMI current sensor readings in amperes",
+			thresholdName: "ipmi_current_threshold_amperes",
+			thresholdHelp: "IPMI current sensor thresholds in amperes",
+		},
+		{
+			sensorType:    "airflow",
+			name:          "ipmi_airflow_cfm",
+			help:          "IPMI airflow sensor readings in cubic feet per minute",
+			thresholdName: "ipmi_airflow_threshold_cfm",
+			thresholdHelp: "IPMI airflow sensor thresholds in cubic feet per minute",
+		},
+		{
+			sensorType:    "humidity",
+			name:          "ipmi_humidity_percent",
+			help:          "IPMI relative humidity sensor readings in percent",
+			thresholdName: "ipmi_humidity_threshold_percent",
+			thresholdHelp: "IPMI relative humidity sensor thresholds in percent",
+		},
+		{
+			sensorType:    "percent",
+			name:          "ipmi_sensor_percent",
+			help:          "IPMI sensor readings in percent, such as utilization, from sensors not read as fan duty cycle or humidity",
+			thresholdName: "ipmi_sensor_threshold_percent",
+			thresholdHelp: "IPMI percent sensor thresholds in percent",
+		},
+		{
+			sensorType: "energy",
+			name:       "ipmi_energy_joules",
+			help:       "IPMI energy sensor readings in joules",
+		},
+		{
+			sensorType: "raw",
+			name:       "ipmi_sensor_raw",
+			help:       "IPMI sensor readings given as a raw hex value without a unit, decoded to an integer",
+		},
+	}
+}
+
+// gaugeDescs are the descriptors of a sensorGauge.
+type gaugeDescs struct {
+	value *prometheus.Desc
+	// threshold is nil for types without thresholds.
+	threshold *prometheus.Desc
+	label     string
+}
+
+// newGaugeDescs returns the descriptors of gauges by sensor type, with the
+// sensor labels, fully qualified names and constant labels given.
+func newGaugeDescs(gauges []sensorGauge, labels []string, name func(string) string, constLabels prometheus.Labels) map[string]gaugeDescs {
+	thresholdLabels := append(slices.Clone(labels), "level")
+	descs := make(map[string]gaugeDescs, len(gauges))
+	for _, g := range gauges {
+		valueLabels := labels
+		if g.label != "" {
+			valueLabels = append(slices.Clone(labels), g.label)
+		}
+		d := gaugeDescs{
+			value: prometheus.NewDesc(name(g.name), g.help, valueLabels, constLabels),
+			label: g.label,
+		}
+		if g.thresholdName != "" {
+			d.threshold = prometheus.NewDesc(name(g.thresholdName), g.thresholdHelp, thresholdLabels, constLabels)
+		}
+		descs[g.sensorType] = d
+	}
+	return descs
+}
+
+// gaugeLabelValue returns the value of the extra sensorGauge label of sensor.
+func (c *Collector) gaugeLabelValue(label string, sensor SensorData) string {
+	switch label {
+	case "direction":
+		return sensor.Direction
+	case "fan_id":
+		return sensor.FanID
+	case "rail":
+		if label, ok := c.rails[changeKey{sensor.Name, sensor.ID}]; ok {
+			return label
+		}
+		return classifyRail(sensor.Value, c.opts.rails)
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// retryBaseBackoff is the delay before the first retry of a failed ipmitool
+// command; it doubles with every further attempt.
+const retryBaseBackoff = 500 * time.Millisecond
+
+// executeIPMICommand runs ipmitool against the BMC of the collector with the
+// given subcommand arguments and returns its standard output. Transient
+// session errors are retried up to WithRetries times with exponential
+// backoff. Cancelling ctx kills a running ipmitool and stops further retries.
+// Commands that still fail are counted by cause, unless ctx was cancelled.
+func (c *Collector) executeIPMICommand(ctx context.Context, args ...string) (string, error) {
+	backoff := retryBaseBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := c.runIPMICommand(ctx, args...)
+		if err == nil || ctx.Err() != nil {
+			return output, err
+		}
+		if attempt >= c.opts.retries || !isRetryableError(err) {
+			c.metrics.commandErrorsTotal.WithLabelValues(c.targetLabels(classifyError(err))...).Inc()
+			return output, err
+		}
+
+		c.metrics.commandRetriesTotal.WithLabelValues(c.targetLabels()...).Inc()
+		slog.Debug("Retrying ipmitool command", "host", c.config.Host, "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// commandDuration is the longest executeIPMICommand can take with o: every
+// attempt may run into the timeout, followed by the backoff before the next.
+func (o options) commandDuration() time.Duration {
+	total, backoff := o.timeout, retryBaseBackoff
+	for range o.retries {
+		total += backoff + o.timeout
+		backoff *= 2
+	}
+	return total
+}
+
+// MaxCommandDuration returns the longest a single BMC command, such as the
+// one run by RefreshBMCInfo, can take with opts, retries included.
+func MaxCommandDuration(opts ...Option) time.Duration {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.commandDuration()
+}
+
+// MaxRefreshDuration returns the longest Refresh can take with opts: the
+// duration of every command the enabled collectors run, each with its
+// retries. With WithBackend the sensors are one read bounded by WithTimeout.
+func MaxRefreshDuration(opts ...Option) time.Duration {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var total time.Duration
+	if o.backend != nil {
+		total = o.timeout
+	} else {
+		queries := 1
+		if types := sdrTypeQueries(o.types); o.sdrTypeQueries && types != nil {
+			queries = len(types)
+		}
+		if o.thresholds {
+			queries++
+		}
+		total = time.Duration(queries) * o.commandDuration()
+	}
+
+	commands := 0
+	if o.dcmi {
+		commands++
+		if o.dcmiPeriod != "" {
+			// The reading is retried without the period if that fails.
+			commands++
+		}
+	}
+	for _, enabled := range []bool{o.sel, o.chassis, o.watchdog} {
+		if enabled {
+			commands++
+		}
+	}
+	return total + time.Duration(commands)*o.commandDuration()
+}
+
+// ipmiInterfaces lists the ipmitool interfaces accepted for -I.
+var ipmiInterfaces = []string{"lan", "lanplus", "open"}
+
+// ValidateInterface checks that iface is empty or a supported interface.
+func ValidateInterface(iface string) error {
+	if iface == "" || slices.Contains(ipmiInterfaces, iface) {
+		return nil
+	}
+	return fmt.Errorf("invalid interface %q: must be one of %s", iface, strings.Join(ipmiInterfaces, ", "))
+}
+
+// ipmitoolArgs builds the full ipmitool argument list for running the
+// subcommand args against the BMC described by config, with the LAN session
+// settings of o. The open interface talks to the local BMC through the
+// kernel driver and takes no host, credentials or LAN settings. config must
+// have its defaults filled in.
+func ipmitoolArgs(config IPMIConfig, o options, args ...string) []string {
+	if config.Interface == "open" {
+		cmdArgs := []string{"-I", config.Interface}
+		if config.OEM != "" {
+			cmdArgs = append(cmdArgs, "-o", config.OEM)
+		}
+		return append(cmdArgs, args...)
+	}
+
+	cmdArgs := []string{
+		"-I", config.Interface,
+		"-H", config.Host,
+		"-p", strconv.Itoa(config.Port),
+		"-U", config.Username,
+		"-E",
+	}
+	if config.CipherSuite != "" {
+		cmdArgs = append(cmdArgs, "-C", config.CipherSuite)
+	}
+	if config.OEM != "" {
+		cmdArgs = append(cmdArgs, "-o", config.OEM)
+	}
+	if o.sessionTimeout > 0 {
+		seconds := int((o.sessionTimeout + time.Second - 1) / time.Second)
+		cmdArgs = append(cmdArgs, "-N", strconv.Itoa(seconds))
+	}
+	if o.lanRetries > 0 {
+		cmdArgs = append(cmdArgs, "-R", strconv.Itoa(o.lanRetries))
+	}
+	return append(cmdArgs, args...)
+}
+
+// oemTypePattern matches ipmitool OEM type names such as "supermicro" or
+// "intelplus". The value is passed through to -o, so anything that could be
+// read as another option is rejected.
+var oemTypePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// ValidateOEM checks that oem is empty or a well-formed OEM type name.
+func ValidateOEM(oem string) error {
+	if oem == "" || oemTypePattern.MatchString(oem) {
+		return nil
+	}
+	return fmt.Errorf("invalid OEM type %q: must consist of lowercase letters, digits and underscores", oem)
+}
+
+// ValidateCipherSuite checks that suite is empty or a cipher suite ID
+// ipmitool accepts for -C.
+func ValidateCipherSuite(suite string) error {
+	if suite == "" {
+		return nil
+	}
+	id, err := strconv.Atoi(suite)
+	if err != nil || id < 0 || id > 17 {
+		return fmt.Errorf("invalid cipher suite %q: must be an integer between 0 and 17", suite)
+	}
+	return nil
+}
+
+// runIPMICommand performs a single ipmitool invocation. If ipmitool exits
+// with an error, whatever it printed to standard output is returned with it.
+func (c *Collector) runIPMICommand(parent context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, c.opts.timeout)
+	defer cancel()
+
+	config := c.config
+	start := time.Now()
+	stdout, stderr, err := c.opts.runner.Run(ctx, ipmitoolArgs(config, c.opts, args...), config.Password)
+	addSessionTime(parent, time.Since(start))
+	if parent.Err() != nil {
+		return "", fmt.Errorf("ipmitool command for %s cancelled: %w", config.Address(), parent.Err())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("ipmitool command for %s timed out after %s: %w", config.Address(), c.opts.timeout, ctx.Err())
+	}
+	output := normalizeOutput(stdout)
+	if err != nil {
+		if len(stderr) > 0 {
+			return output, fmt.Errorf("failed to execute ipmitool command: %v: %s", err, strings.TrimSpace(string(stderr)))
+		}
+		return output, fmt.Errorf("failed to execute ipmitool command: %v", err)
+	}
+
+	return output, nil
+}
+
+// normalizeOutput drops the carriage returns of CRLF line endings, printed by
+// ipmitool on Windows or by an SSH relay allocating a terminal, and any other
+// control characters but newlines and tabs, which the parsers would
+// otherwise keep in names and values.
+func normalizeOutput(output []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || !unicode.IsControl(r) {
+			return r
+		}
+		return -1
+	}, string(output))
+}
+
+// authErrorMarkers identify ipmitool failures caused by bad credentials,
+// which will not recover on retry.
+var authErrorMarkers = []string{
+	"unauthorized name",
+	"invalid user name",
+	"rakp 2 hmac is invalid",
+	"rakp 4 message has invalid integrity check",
+	"password invalid",
+	"authentication type",
+}
+
+// sessionErrorMarkers identify transient session and connection failures.
+var sessionErrorMarkers = []string{
+	"unable to establish",
+	"get session challenge command failed",
+	"activate session command failed",
+	"insufficient resources for session",
+	"no response from remote controller",
+	"connection timed out",
+}
+
+// connectionErrorMarkers identify failures to reach the BMC or relay at all.
+var connectionErrorMarkers = []string{
+	"connection refused",
+	"no route to host",
+	"network is unreachable",
+	"name or service not known",
+	"failed to connect to ssh relay",
+}
+
+// classifyError returns the cause of a failed ipmitool command for
+// ipmi_command_errors_total: auth, timeout, connection, unsupported or
+// unknown.
+func classifyError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range authErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return "auth"
+		}
+	}
+	for _, marker := range slices.Concat(sessionErrorMarkers, connectionErrorMarkers) {
+		if strings.Contains(msg, marker) {
+			return "connection"
+		}
+	}
+	if isUnsupportedError(err) {
+		return "unsupported"
+	}
+	return "unknown"
+}
+
+// isRetryableError reports whether err looks like a transient session or
+// connection failure rather than an authentication problem.
+func isRetryableError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, marker := range authErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return false
+		}
+	}
+	for _, marker := range sessionErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// hangingRunner is a CommandRunner whose commands never finish on their own,
+// like those sent to a BMC that stopped responding.
+type hangingRunner struct{}
+
+// Run implements CommandRunner.
+func (hangingRunner) Run(ctx context.Context, _ []string, _ string) ([]byte, []byte, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func TestExecuteIPMICommandTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	collector := NewCollector(IPMIConfig{Host: "bmc1"}, WithRunner(hangingRunner{}), WithTimeout(timeout))
+
+	start := time.Now()
+	_, err := collector.executeIPMICommand(context.Background(), "sdr", "elist", "full")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("executeIPMICommand() = %v, want a timeout error", err)
+	}
+	// Timeouts are not retried, so the command is given up on after one.
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("executeIPMICommand() returned after %v, want about %v", elapsed, timeout)
+	}
+	want := `
+# HELP ipmi_command_errors_total Number of failed ipmitool commands by cause: auth, timeout, connection, unsupported or unknown
+# TYPE ipmi_command_errors_total counter
+ipmi_command_errors_total{host="bmc1",instance_name="bmc1",reason="timeout"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "ipmi_command_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIPMIToolArgs(t *testing.T) {
+	lanplus := IPMIConfig{Host: "10.0.0.10", Port: 623, Username: "admin", Password: "secret", Interface: "lanplus"}
+	withCipherSuite := lanplus
+	withCipherSuite.CipherSuite = "17"
+	withOEM := lanplus
+	withOEM.OEM = "supermicro"
+	tests := []struct {
+		name   string
+		config IPMIConfig
+		opts   []Option
+		want   []string
+	}{
+		{
+			name:   "lanplus",
+			config: lanplus,
+			want:   []string{"-I", "lanplus", "-H", "10.0.0.10", "-p", "623", "-U", "admin", "-E", "sdr", "elist", "full"},
+		},
+		{
+			name:   "cipher suite",
+			config: withCipherSuite,
+			want:   []string{"-I", "lanplus", "-H", "10.0.0.10", "-p", "623", "-U", "admin", "-E", "-C", "17", "sdr", "elist", "full"},
+		},
+		{
+			name:   "oem",
+			config: withOEM,
+			want:   []string{"-I", "lanplus", "-H", "10.0.0.10", "-p", "623", "-U", "admin", "-E", "-o", "supermicro", "sdr", "elist", "full"},
+		},
+		{
+			// -N is whole seconds, rounded up.
+			name:   "session timeout and lan retries",
+			config: lanplus,
+			opts:   []Option{WithSessionTimeout(1500 * time.Millisecond), WithLANRetries(2)},
+			want:   []string{"-I", "lanplus", "-H", "10.0.0.10", "-p", "623", "-U", "admin", "-E", "-N", "2", "-R", "2", "sdr", "elist", "full"},
+		},
+		{
+			name:   "open",
+			config: IPMIConfig{Host: "localhost", Port: 623, Interface: "open"},
+			opts:   []Option{WithSessionTimeout(time.Second), WithLANRetries(2)},
+			want:   []string{"-I", "open", "sdr", "elist", "full"},
+		},
+		{
+			name:   "open with oem",
+			config: IPMIConfig{Host: "localhost", Interface: "open", OEM: "intelplus"},
+			want:   []string{"-I", "open", "-o", "intelplus", "sdr", "elist", "full"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			for _, opt := range tt.opts {
+				opt(&o)
+			}
+			got := ipmitoolArgs(tt.config, o, "sdr", "elist", "full")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ipmitoolArgs() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(strings.Join(got, " "), "secret") {
+				t.Errorf("ipmitoolArgs() = %q, carries the password", got)
+			}
+		})
+	}
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	tests := []struct {
+		output, want string
+	}{
+		{"CPU Temp | 01h | ok | 3.1 | 45 degrees C\r\n", "CPU Temp | 01h | ok | 3.1 | 45 degrees C\n"},
+		{"Fan1\x00 | 30h | ok\t| 29.1 | 5400 RPM\n", "Fan1 | 30h | ok\t| 29.1 | 5400 RPM\n"},
+		{"\x1b[0mPS1 Status | c8h\r\n", "[0mPS1 Status | c8h\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeOutput([]byte(tt.output)); got != tt.want {
+			t.Errorf("normalizeOutput(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ipmicollector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are counters about the collector's own operation, labeled by host
+// and instance name.
+// They accumulate across refreshes. Collectors given the same Metrics with
+// WithMetrics share them, and the caller registers them once; otherwise each
+// Collector exports its own.
+type Metrics struct {
+	commandRetriesTotal    *prometheus.CounterVec
+	commandErrorsTotal     *prometheus.CounterVec
+	sensorParseErrorsTotal *prometheus.CounterVec
+	sensorsFilteredTotal   *prometheus.CounterVec
+	collectionSkippedTotal *prometheus.CounterVec
+	seriesDroppedTotal     *prometheus.CounterVec
+}
+
+// NewMetrics creates the counters with names prefixed by namespace.
+func NewMetrics(namespace string) *Metrics {
+	targetLabels := []string{"host", "instance_name"}
+	return &Metrics{
+		commandRetriesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_command_retries_total",
+				Help:      "Number of ipmitool commands retried after a transient session error",
+			},
+			targetLabels,
+		),
+		commandErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_command_errors_total",
+				Help:      "Number of failed ipmitool commands by cause: auth, timeout, connection, unsupported or unknown",
+			},
+			[]string{"host", "instance_name", "reason"},
+		),
+		sensorParseErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_sensor_parse_errors_total",
+				Help:      "Number of sdr lines that matched the sensor format but whose value could not be parsed",
+			},
+			targetLabels,
+		),
+		sensorsFilteredTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_sensors_filtered_total",
+				Help:      "Number of sensors dropped by the sensor name filter",
+			},
+			targetLabels,
+		),
+		collectionSkippedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_collection_skipped_total",
+				Help:      "Number of collections skipped because the previous one from the same BMC was still running",
+			},
+			targetLabels,
+		),
+		seriesDroppedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "ipmi_series_dropped_total",
+				Help:      "Number of sensor series left out of scrapes for exceeding the per-host series limit",
+			},
+			targetLabels,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.commandRetriesTotal.Describe(ch)
+	m.commandErrorsTotal.Describe(ch)
+	m.sensorParseErrorsTotal.Describe(ch)
+	m.sensorsFilteredTotal.Describe(ch)
+	m.collectionSkippedTotal.Describe(ch)
+	m.seriesDroppedTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.commandRetriesTotal.Collect(ch)
+	m.commandErrorsTotal.Collect(ch)
+	m.sensorParseErrorsTotal.Collect(ch)
+	m.sensorsFilteredTotal.Collect(ch)
+	m.collectionSkippedTotal.Collect(ch)
+	m.seriesDroppedTotal.Collect(ch)
+}
+
+// Delete removes the counters labeled with host and instanceName, for a
+// collector sharing m that was dropped and won't update them any more.
+func (m *Metrics) Delete(host, instanceName string) {
+	labels := prometheus.Labels{"host": host, "instance_name": instanceName}
+	m.commandRetriesTotal.DeletePartialMatch(labels)
+	m.commandErrorsTotal.DeletePartialMatch(labels)
+	m.sensorParseErrorsTotal.DeletePartialMatch(labels)
+	m.sensorsFilteredTotal.DeletePartialMatch(labels)
+	m.collectionSkippedTotal.DeletePartialMatch(labels)
+	m.seriesDroppedTotal.DeletePartialMatch(labels)
+}
@@ -0,0 +1,346 @@
+package ipmicollector
+
+import (
+	"time"
+)
+
+// Default values of the options controlling ipmitool.
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 2
+)
+
+// options control how a Collector talks to its BMC and turns sensors into
+// metrics.
+type options struct {
+	// entityLabel adds the sensor entity (e.g. "7.1") as an "entity" label.
+	entityLabel bool
+	// normalizeNames adds a "sensor" label holding the lowercased sensor
+	// name with runs of other characters replaced by underscores.
+	normalizeNames bool
+	// clearOnError drops the cached readings when a collection fails, so
+	// only ipmi_up and ipmi_scrape_duration_seconds remain for the host.
+	clearOnError bool
+	// types restricts the exported sensors to these WithSensorTypes
+	// categories. Nil exports every sensor.
+	types map[string]bool
+	// cacheTTL, when positive, stops serving cached readings older than this
+	// so a stalled poller doesn't present stale data as current.
+	cacheTTL time.Duration
+	// temperatureUnit is the unit temperatures are exported in.
+	temperatureUnit temperatureUnit
+	// timestamps attaches the collection time to sensor samples instead of
+	// letting Prometheus use the scrape time.
+	timestamps bool
+	// trackChanges remembers the last reading of every sensor to export when
+	// it last changed.
+	trackChanges bool
+	// energyCounters exports energy sensors as counters instead of gauges.
+	energyCounters bool
+	// trackBreaches remembers the last status of every sensor to count
+	// threshold breaches.
+	trackBreaches bool
+	// sensorInfo exports ipmi_sensor_info.
+	sensorInfo bool
+	// breaker configures the circuit breaker of WithCircuitBreaker.
+	breaker circuitBreaker
+	// maxSeries, when positive, caps the sensor series exported per scrape.
+	maxSeries int
+	// namespace is prepended to every metric name.
+	namespace string
+	// hostLabel is the value of the "host" label. NewCollector sets it to
+	// the configured host unless WithHostLabel overrides it.
+	hostLabel string
+	// rails are the nominal voltages voltage sensors are classified into for
+	// the "rail" label. Nil omits the label.
+	rails []float64
+
+	// timeout bounds a single ipmitool invocation or backend read.
+	timeout time.Duration
+	// retries is how often a command failing with a transient session
+	// error is retried.
+	retries int
+	// sessionTimeout and lanRetries are passed to ipmitool as -N and -R,
+	// controlling its own retransmission of LAN packets. Zero leaves
+	// ipmitool's default.
+	sessionTimeout time.Duration
+	lanRetries     int
+	// filter selects the sensors collected by name.
+	filter SensorFilter
+	// sdrTypeQueries lists the sensors with one `sdr type` call per
+	// WithSensorTypes type instead of a single `sdr elist`.
+	sdrTypeQueries bool
+	// sdrFormat is the listing format of the sensors when not using
+	// sdrTypeQueries.
+	sdrFormat sdrFormat
+	// thresholds, dcmi, sel, chassis and watchdog enable the optional
+	// ipmitool calls made in addition to sdr elist on every refresh.
+	thresholds bool
+	dcmi       bool
+	sel        bool
+	chassis    bool
+	watchdog   bool
+	// dcmiPeriod is the averaging period requested for DCMI power readings.
+	dcmiPeriod string
+
+	runner CommandRunner
+	// backend, when set, reads the sensors instead of ipmitool sdr.
+	backend SensorBackend
+	metrics *Metrics
+}
+
+// defaultOptions returns the options of a Collector given none.
+func defaultOptions() options {
+	return options{
+		temperatureUnit: temperatureUnits[0],
+		sdrFormat:       sdrFormats[0],
+		timeout:         DefaultTimeout,
+		retries:         DefaultRetries,
+		runner:          LocalRunner{},
+	}
+}
+
+// Option configures a Collector.
+type Option func(*options)
+
+// WithEntityLabel adds the sensor entity (physical location, e.g. "7.1") as
+// an "entity" label. It increases cardinality.
+func WithEntityLabel() Option {
+	return func(o *options) { o.entityLabel = true }
+}
+
+// WithNormalizedNames adds a "sensor" label with the sensor name lowercased
+// and non-alphanumeric characters replaced by underscores.
+func WithNormalizedNames() Option {
+	return func(o *options) { o.normalizeNames = true }
+}
+
+// WithClearOnError drops the readings of a failed refresh instead of
+// serving the previous ones, so only ipmi_up and the collection metrics
+// remain for the host.
+func WithClearOnError() Option {
+	return func(o *options) { o.clearOnError = true }
+}
+
+// WithSensorTypes exports only sensors of the given types, as returned by
+// ParseSensorTypes. Without types every sensor is exported.
+// ipmi_sensors_collected only counts the sensors exported.
+func WithSensorTypes(types ...string) Option {
+	return func(o *options) {
+		o.types = nil
+		for _, t := range types {
+			if o.types == nil {
+				o.types = make(map[string]bool)
+			}
+			o.types[t] = true
+		}
+	}
+}
+
+// WithCacheTTL stops serving readings older than ttl. Zero serves the last
+// readings regardless of age.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(o *options) { o.cacheTTL = ttl }
+}
+
+// WithTemperatureUnit exports temperatures in the named unit: celsius, the
+// default, fahrenheit or kelvin. Unknown units are ignored; check them with
+// ValidateTemperatureUnit.
+func WithTemperatureUnit(name string) Option {
+	return func(o *options) {
+		if unit, err := lookupTemperatureUnit(name); err == nil {
+			o.temperatureUnit = unit
+		}
+	}
+}
+
+// WithSDRFormat lists sensors in the named format: elist, the default, for
+// `sdr elist full`, list for `sdr list full`, which lacks sensor IDs and
+// entities, or v for `-v sdr list full`, for ipmitool builds or BMCs whose
+// elist output doesn't parse. Unknown formats are ignored; check them with
+// ValidateSDRFormat. WithSDRTypeQueries always uses the elist layout.
+func WithSDRFormat(name string) Option {
+	return func(o *options) {
+		if format, err := lookupSDRFormat(name); err == nil {
+			o.sdrFormat = format
+		}
+	}
+}
+
+// WithTimestamps exposes sensor samples with the time they were collected
+// from the BMC instead of the scrape time.
+func WithTimestamps() Option {
+	return func(o *options) { o.timestamps = true }
+}
+
+// WithMaxSeries exports at most limit sensor series per scrape, as a guard
+// against a BMC or parsing bug producing unbounded label combinations. The
+// excess is dropped and counted in ipmi_series_dropped_total. Collection
+// metrics such as ipmi_up don't count towards the limit. Zero disables it.
+func WithMaxSeries(limit int) Option {
+	return func(o *options) { o.maxSeries = limit }
+}
+
+// WithHostLabel exports label as the "host" label instead of the configured
+// host, e.g. the FQDN of a BMC configured by IP address. ipmitool still
+// connects to the configured host.
+func WithHostLabel(label string) Option {
+	return func(o *options) { o.hostLabel = label }
+}
+
+// WithLastChange exports ipmi_sensor_last_change_timestamp_seconds, the time
+// of the first refresh that returned a sensor's current reading, to spot
+// stuck sensors. It keeps the last reading of every sensor in memory.
+func WithLastChange() Option {
+	return func(o *options) { o.trackChanges = true }
+}
+
+// WithThresholdBreaches exports ipmi_sensor_threshold_breaches_total, how
+// many times the status of a sensor went from ok to nc, cr or nr since the
+// collector was created. It keeps the last status of every sensor in memory.
+func WithThresholdBreaches() Option {
+	return func(o *options) { o.trackBreaches = true }
+}
+
+// WithEnergyCounters exports energy sensors, which report the running total
+// of the energy consumed, as the counter ipmi_energy_joules_total instead of
+// the gauge ipmi_energy_joules, so rate() works on them. A reading dropping
+// under half the previous one is taken as the BMC resetting the total and the
+// counter continues from where it was. With WithDCMI it also exports
+// ipmi_dcmi_energy_joules_total, integrated from the DCMI power readings, for
+// BMCs without energy sensors.
+func WithEnergyCounters() Option {
+	return func(o *options) { o.energyCounters = true }
+}
+
+// WithSensorInfo exports ipmi_sensor_info, a constant 1 per sensor labeled
+// with the status, unit and entity exactly as ipmitool printed them, for
+// troubleshooting. The status label changes whenever a threshold is crossed,
+// so it creates a new series each time.
+func WithSensorInfo() Option {
+	return func(o *options) { o.sensorInfo = true }
+}
+
+// WithCircuitBreaker holds off collections from a BMC after failures
+// consecutive failed refreshes: Refresh returns ErrCircuitOpen without
+// running ipmitool until backoff has passed, then lets one attempt through.
+// Each further failure doubles the wait, up to maxBackoff, and the first
+// success restores normal operation. ipmi_host_circuit_open reports whether
+// collections are held off. Zero failures disables it.
+func WithCircuitBreaker(failures int, backoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		o.breaker = circuitBreaker{threshold: failures, backoff: backoff, maxBackoff: max(backoff, maxBackoff)}
+	}
+}
+
+// WithNamespace prepends namespace to every metric name, e.g. acme for
+// acme_ipmi_up.
+func WithNamespace(namespace string) Option {
+	return func(o *options) { o.namespace = namespace }
+}
+
+// WithVoltageRails adds a "rail" label to ipmi_voltage_volts naming the
+// nominal voltage in rails, as parsed by ParseVoltageRails, of a sensor, such
+// as "12v". It is taken from the sensor name, as in "P12V", or else from the
+// nominal voltage the first reading is closest to, or "other" if that is more
+// than 15% off all of them. A sensor keeps its label for as long as it is
+// reported.
+func WithVoltageRails(rails ...float64) Option {
+	return func(o *options) { o.rails = rails }
+}
+
+// WithTimeout bounds how long a single ipmitool invocation, or a read from
+// the WithBackend backend, may run before it is cancelled. The default is
+// DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithRetries sets how often an ipmitool command is retried after a
+// transient session or connection error. The default is DefaultRetries.
+func WithRetries(retries int) Option {
+	return func(o *options) { o.retries = retries }
+}
+
+// WithSessionTimeout passes timeout to ipmitool as -N, the time it waits for
+// a response before retransmitting a LAN packet, rounded up to whole seconds.
+// Unlike WithTimeout it applies to every packet of a command. Zero leaves
+// ipmitool's default.
+func WithSessionTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.sessionTimeout = timeout }
+}
+
+// WithLANRetries passes retries to ipmitool as -R, how often it retransmits an
+// unanswered LAN packet before giving up on the command. Zero leaves
+// ipmitool's default.
+func WithLANRetries(retries int) Option {
+	return func(o *options) { o.lanRetries = retries }
+}
+
+// WithSensorFilter collects only the sensors allowed by filter.
+func WithSensorFilter(filter SensorFilter) Option {
+	return func(o *options) { o.filter = filter }
+}
+
+// WithSDRTypeQueries lists sensors with one 'ipmitool sdr type' call per
+// WithSensorTypes type, so the BMC only reads the selected sensors, instead of
+// a single 'sdr elist full' of all of them. Each call is a separate session,
+// so whether it is faster depends on the BMC and the number of types;
+// compare ipmi_session_duration_seconds. It only applies when every type
+// passes ValidateSDRTypes and is ignored otherwise.
+func WithSDRTypeQueries() Option {
+	return func(o *options) { o.sdrTypeQueries = true }
+}
+
+// WithThresholds collects sensor thresholds via an additional
+// 'ipmitool sensor' call per refresh.
+func WithThresholds() Option {
+	return func(o *options) { o.thresholds = true }
+}
+
+// WithDCMI collects system power via an additional 'ipmitool dcmi power
+// reading' call per refresh, averaged over period if it is not empty, e.g.
+// 5_min. BMCs that reject the period are queried without one.
+func WithDCMI(period string) Option {
+	return func(o *options) {
+		o.dcmi = true
+		o.dcmiPeriod = period
+	}
+}
+
+// WithSEL collects System Event Log usage via an additional 'ipmitool sel
+// info' call per refresh.
+func WithSEL() Option {
+	return func(o *options) { o.sel = true }
+}
+
+// WithChassis collects the chassis power state and last power event via an
+// additional 'ipmitool chassis status' call per refresh.
+func WithChassis() Option {
+	return func(o *options) { o.chassis = true }
+}
+
+// WithWatchdog collects whether the BMC watchdog timer is running and its
+// countdown via an additional 'ipmitool mc watchdog get' call per refresh.
+func WithWatchdog() Option {
+	return func(o *options) { o.watchdog = true }
+}
+
+// WithRunner runs ipmitool through runner instead of as a local child
+// process.
+func WithRunner(runner CommandRunner) Option {
+	return func(o *options) { o.runner = runner }
+}
+
+// WithBackend reads sensors from backend instead of `ipmitool sdr`. The
+// optional collectors such as WithDCMI still use ipmitool, and WithThresholds
+// has no effect since backends return thresholds with the sensors.
+func WithBackend(backend SensorBackend) Option {
+	return func(o *options) { o.backend = backend }
+}
+
+// WithMetrics records the collector's own counters in metrics, which the
+// caller registers. Without it the Collector exports counters of its own.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) { o.metrics = metrics }
+}
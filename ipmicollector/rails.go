@@ -0,0 +1,109 @@
+package ipmicollector
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// railTolerance is how far a voltage reading may deviate from a nominal
+// voltage, relative to it, and still be classified as that rail. It is wider
+// than the ATX tolerance of 5% so a sagging rail keeps its label while its
+// thresholds flag it.
+const railTolerance = 0.15
+
+// otherRail is the rail label of voltages near none of the nominal rails.
+const otherRail = "other"
+
+// classifyRail returns the rail label, such as "12v", of the nominal
+// voltage in rails closest to value relative to its size, or otherRail if
+// none is within railTolerance.
+func classifyRail(value float64, rails []float64) string {
+	label, best := otherRail, railTolerance
+	for _, nominal := range rails {
+		if deviation := math.Abs(value-nominal) / math.Abs(nominal); deviation <= best {
+			label, best = railLabel(nominal), deviation
+		}
+	}
+	return label
+}
+
+var (
+	// railNameSplit matches a voltage written with V as the decimal point
+	// in a sensor name, such as "P3V3" or "1V05".
+	railNameSplit = regexp.MustCompile(`(?i)(?:^|[^0-9.])(\d+)V(\d+)`)
+	// railNameVolts matches a voltage such as "12V", "+3.3V" or "5VSB" in a
+	// sensor name.
+	railNameVolts = regexp.MustCompile(`(?i)(?:^|[^0-9.])(\d+(?:\.\d+)?)V(?:[^0-9]|$)`)
+)
+
+// nameRail returns the rail label of the nominal voltage in rails named by a
+// sensor, such as "P12V" or "3.3V Standby", and "" if the name gives none of
+// them.
+func nameRail(name string, rails []float64) string {
+	var text string
+	if m := railNameSplit.FindStringSubmatch(name); m != nil {
+		text = m[1] + "." + m[2]
+	} else if m := railNameVolts.FindStringSubmatch(name); m != nil {
+		text = m[1]
+	}
+	nominal, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return ""
+	}
+	for _, rail := range rails {
+		if rail == nominal {
+			return railLabel(rail)
+		}
+	}
+	return ""
+}
+
+// trackRails returns the rail labels of the voltage sensors in sensors. A
+// sensor keeps the label it got when first seen, taken from its name if that
+// gives one of rails and from its reading otherwise, so a rail sagging out of
+// railTolerance doesn't change series. As with trackChanges, sensors no
+// longer reported are forgotten.
+func trackRails(previous map[changeKey]string, sensors []SensorData, rails []float64) map[changeKey]string {
+	labels := make(map[changeKey]string)
+	for _, sensor := range sensors {
+		if sensor.Type != "voltage" || sensor.NoReading {
+			continue
+		}
+		key := changeKey{sensor.Name, sensor.ID}
+		label, ok := previous[key]
+		if !ok {
+			if label = nameRail(sensor.Name, rails); label == "" {
+				label = classifyRail(sensor.Value, rails)
+			}
+		}
+		labels[key] = label
+	}
+	return labels
+}
+
+// railLabel formats a nominal voltage as a rail label, e.g. 3.3 as "3.3v".
+func railLabel(nominal float64) string {
+	return strconv.FormatFloat(nominal, 'f', -1, 64) + "v"
+}
+
+// ParseVoltageRails parses a comma-separated list of nominal voltages for
+// WithVoltageRails, such as "3.3,5,12". A trailing V is allowed. An empty
+// list yields nil.
+func ParseVoltageRails(list string) ([]float64, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var rails []float64
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		nominal, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSuffix(entry, "V"), "v"), 64)
+		if err != nil || nominal == 0 || math.IsInf(nominal, 0) || math.IsNaN(nominal) {
+			return nil, fmt.Errorf("invalid nominal voltage %q: must be a non-zero number of volts", entry)
+		}
+		rails = append(rails, nominal)
+	}
+	return rails, nil
+}
@@ -0,0 +1,91 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testRails = []float64{1.05, 3.3, 5, 12}
+
+func TestClassifyRail(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{12.05, "12v"},
+		{11.5, "12v"},
+		{3.28, "3.3v"},
+		{5.1, "5v"},
+		{1.1, "1.05v"},
+		{8, "other"},
+		{1.5, "other"},
+	}
+	for _, tt := range tests {
+		if got := classifyRail(tt.value, testRails); got != tt.want {
+			t.Errorf("classifyRail(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNameRail(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"P12V", "12v"},
+		{"12V", "12v"},
+		{"+3.3V", "3.3v"},
+		{"P3V3", "3.3v"},
+		{"5VSB", "5v"},
+		{"P1V05_PCH", "1.05v"},
+		{"P1V8", ""},
+		{"VBAT", ""},
+		{"CPU1 Vcore", ""},
+	}
+	for _, tt := range tests {
+		if got := nameRail(tt.name, testRails); got != tt.want {
+			t.Errorf("nameRail(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrackRails(t *testing.T) {
+	first := []SensorData{
+		{Name: "P12V", ID: "20h", Type: "voltage", Value: 10.2},
+		{Name: "Vcore", ID: "21h", Type: "voltage", Value: 3.25},
+		{Name: "CPU Temp", ID: "01h", Type: "temperature", Value: 45},
+	}
+	rails := trackRails(nil, first, testRails)
+	want := map[changeKey]string{{"P12V", "20h"}: "12v", {"Vcore", "21h"}: "3.3v"}
+	if !reflect.DeepEqual(rails, want) {
+		t.Fatalf("first collection: rails = %v, want %v", rails, want)
+	}
+
+	// A reading drifting away from its rail keeps the label.
+	second := []SensorData{{Name: "Vcore", ID: "21h", Type: "voltage", Value: 2.5}}
+	rails = trackRails(rails, second, testRails)
+	want = map[changeKey]string{{"Vcore", "21h"}: "3.3v"}
+	if !reflect.DeepEqual(rails, want) {
+		t.Errorf("second collection: rails = %v, want %v", rails, want)
+	}
+}
+
+func TestParseVoltageRails(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []float64
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"3.3,5,12", []float64{3.3, 5, 12}, false},
+		{" 3.3V, 12v ", []float64{3.3, 12}, false},
+		{"0", nil, true},
+		{"12,volts", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVoltageRails(tt.list)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseVoltageRails(%q) = %v, %v; want %v, error %v", tt.list, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package ipmicollector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// SensorBackend reads the sensors of a BMC by some other means than
+// `ipmitool sdr`, selected with WithBackend. Sensors are returned unfiltered;
+// the Collector applies WithSensorFilter.
+type SensorBackend interface {
+	ReadSensors(ctx context.Context, config IPMIConfig) ([]SensorData, error)
+}
+
+// maxRedfishResponseBytes bounds the size of a single Redfish response.
+const maxRedfishResponseBytes = 8 << 20
+
+// RedfishBackend reads sensors over the Redfish API of BMCs that expose it,
+// from the Thermal and Power resources of every chassis. The BMC is reached
+// at https://<host>, so IPMIConfig.Port is ignored and a non-default port is
+// given as part of Host. Username and Password are sent with basic auth when
+// set; with a client certificate in the TLS config they may be left empty.
+type RedfishBackend struct {
+	client *http.Client
+}
+
+// NewRedfishBackend returns a backend using tlsConfig, which may carry a
+// client certificate and custom root CAs, for connections to the BMC. A nil
+// tlsConfig uses the system defaults.
+func NewRedfishBackend(tlsConfig *tls.Config) *RedfishBackend {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &RedfishBackend{client: &http.Client{Transport: transport}}
+}
+
+// redfishLink is a reference to another Redfish resource.
+type redfishLink struct {
+	ID string `json:"@odata.id"`
+}
+
+// redfishStatus is the Status object of a Redfish resource.
+type redfishStatus struct {
+	State  string
+	Health string
+}
+
+// redfishReading holds the fields shared by Redfish temperature, fan and
+// voltage readings. Readings and thresholds are null when not reported.
+type redfishReading struct {
+	MemberID                  string `json:"MemberId"`
+	Name                      string
+	Status                    redfishStatus
+	ReadingCelsius            *float64
+	ReadingVolts              *float64
+	Reading                   *float64
+	ReadingUnits              string
+	UpperThresholdNonCritical *float64
+	UpperThresholdCritical    *float64
+	LowerThresholdNonCritical *float64
+	LowerThresholdCritical    *float64
+}
+
+type redfishThermal struct {
+	Temperatures []redfishReading
+	Fans         []redfishReading
+}
+
+type redfishPower struct {
+	PowerControl []struct {
+		MemberID           string `json:"MemberId"`
+		Name               string
+		PowerConsumedWatts *float64
+	}
+	Voltages      []redfishReading
+	PowerSupplies []struct {
+		MemberID         string `json:"MemberId"`
+		Name             string
+		Status           redfishStatus
+		PowerInputWatts  *float64
+		PowerOutputWatts *float64
+	}
+}
+
+// redfishHealth maps the Redfish Health property to the sdr status values.
+var redfishHealth = map[string]string{
+	"OK":       "ok",
+	"Warning":  "nc",
+	"Critical": "cr",
+}
+
+// ReadSensors implements SensorBackend. Chassis without a Thermal or Power
+// resource are skipped.
+func (b *RedfishBackend) ReadSensors(ctx context.Context, config IPMIConfig) ([]SensorData, error) {
+	base := "https://" + redfishHost(config.Host)
+
+	var chassis struct{ Members []redfishLink }
+	if err := b.get(ctx, config, base+"/redfish/v1/Chassis", &chassis); err != nil {
+		return nil, err
+	}
+
+	var sensors []SensorData
+	for _, member := range chassis.Members {
+		entity := member.ID[strings.LastIndexByte(member.ID, '/')+1:]
+
+		var thermal redfishThermal
+		err := b.get(ctx, config, base+member.ID+"/Thermal", &thermal)
+		switch {
+		case err == nil:
+			sensors = append(sensors, thermalSensors(thermal, entity)...)
+		case !errors.Is(err, errRedfishNotFound):
+			return nil, err
+		}
+
+		var power redfishPower
+		err = b.get(ctx, config, base+member.ID+"/Power", &power)
+		switch {
+		case err == nil:
+			sensors = append(sensors, powerSensors(power, entity)...)
+		case !errors.Is(err, errRedfishNotFound):
+			return nil, err
+		}
+	}
+	return sensors, nil
+}
+
+// errRedfishNotFound is returned by get for resources the BMC doesn't have.
+var errRedfishNotFound = errors.New("resource not found")
+
+// get fetches the Redfish resource at url and decodes it into v.
+func (b *RedfishBackend) get(ctx context.Context, config IPMIConfig, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("redfish request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errRedfishNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("redfish request for %s failed: %s", req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRedfishResponseBytes)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode redfish response for %s: %v", req.URL.Path, err)
+	}
+	return nil
+}
+
+// redfishHost brackets IPv6 addresses for use in a URL.
+func redfishHost(host string) string {
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func thermalSensors(thermal redfishThermal, entity string) []SensorData {
+	var sensors []SensorData
+	for _, t := range thermal.Temperatures {
+		sensors = append(sensors, redfishSensor(t, entity, t.ReadingCelsius, "celsius", "temperature"))
+	}
+	for _, f := range thermal.Fans {
+		unit, sensorType := "rpm", "fan"
+		if strings.EqualFold(f.ReadingUnits, "Percent") {
+			unit, sensorType = "percent", "fan_percent"
+		}
+		sensor := redfishSensor(f, entity, f.Reading, unit, sensorType)
+		sensor.FanID = fanID(f.Name)
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
+
+func powerSensors(power redfishPower, entity string) []SensorData {
+	var sensors []SensorData
+	for _, v := range power.Voltages {
+		sensors = append(sensors, redfishSensor(v, entity, v.ReadingVolts, "volts", "voltage"))
+	}
+	for _, p := range power.PowerControl {
+		sensors = append(sensors, redfishSensor(redfishReading{MemberID: p.MemberID, Name: p.Name}, entity, p.PowerConsumedWatts, "watts", "power"))
+	}
+	for _, ps := range power.PowerSupplies {
+		for _, side := range []struct {
+			direction string
+			watts     *float64
+		}{{"input", ps.PowerInputWatts}, {"output", ps.PowerOutputWatts}} {
+			reading := redfishReading{MemberID: ps.MemberID, Name: ps.Name + " " + side.direction, Status: ps.Status}
+			sensor := redfishSensor(reading, entity, side.watts, "watts", "power")
+			sensor.Direction = side.direction
+			sensors = append(sensors, sensor)
+		}
+	}
+	return sensors
+}
+
+// redfishSensor maps a Redfish reading to a sensor of the given unit and
+// type. Absent, disabled and null readings are reported as NoReading.
+func redfishSensor(r redfishReading, entity string, value *float64, unit, sensorType string) SensorData {
+	sensor := SensorData{
+		Name:   r.Name,
+		ID:     r.MemberID,
+		Status: redfishHealth[r.Status.Health],
+		Entity: entity,
+	}
+	if value == nil || r.Status.State == "Absent" || r.Status.State == "Disabled" {
+		sensor.NoReading = true
+		return sensor
+	}
+	if sensor.Status == "" {
+		sensor.Status = "ok"
+	}
+	sensor.Value = *value
+	sensor.Unit = unit
+	sensor.Type = sensorType
+
+	for level, threshold := range map[string]*float64{
+		"lower_critical":     r.LowerThresholdCritical,
+		"lower_non_critical": r.LowerThresholdNonCritical,
+		"upper_non_critical": r.UpperThresholdNonCritical,
+		"upper_critical":     r.UpperThresholdCritical,
+	} {
+		if threshold == nil {
+			continue
+		}
+		if sensor.Thresholds == nil {
+			sensor.Thresholds = make(map[string]float64)
+		}
+		sensor.Thresholds[level] = *threshold
+	}
+	return sensor
+}
@@ -0,0 +1,58 @@
+package ipmicollector
+
+import (
+	"regexp"
+	"strings"
+)
+
+// fanRedundancySensor matches the names BMCs give to fan redundancy sensors,
+// such as "Fan Redundancy" or "Redundant Fans".
+var fanRedundancySensor = regexp.MustCompile(`(?i)fan.*redundan|redundan.*fan`)
+
+// redundancyPattern matches the readings of redundancy sensors, such as
+// "Fully Redundant" or "Non-Redundant: Sufficient from Redundant".
+const redundancyPattern = `(?i:[\w :-]*redundan)`
+
+var redundancyReading = regexp.MustCompile(`^` + redundancyPattern)
+
+// unknownRedundancy is exported for redundancy states missing from
+// fanRedundancyStates.
+const unknownRedundancy = -1
+
+// fanRedundancyStates maps redundancy readings, normalized by
+// normalizeRedundancy, to the value of ipmi_fan_redundancy. Higher is worse.
+// Readings are matched by prefix, so "Redundancy Degraded from Fully
+// Redundant" counts as degraded.
+var fanRedundancyStates = []struct {
+	prefix string
+	value  float64
+}{
+	{"fully redundant", 0},
+	{"redundancy degraded", 1},
+	{"redundancy lost", 2},
+	{"non redundant sufficient", 3},
+	{"non redundant insufficient", 4},
+}
+
+// parseFanRedundancy returns the ipmi_fan_redundancy value of a reading, or
+// unknownRedundancy. Of a comma-separated list of states the first known one
+// is used.
+func parseFanRedundancy(valueStr string) float64 {
+	for _, part := range strings.Split(valueStr, ",") {
+		reading := normalizeRedundancy(part)
+		for _, state := range fanRedundancyStates {
+			if strings.HasPrefix(reading, state.prefix) {
+				return state.value
+			}
+		}
+	}
+	return unknownRedundancy
+}
+
+// normalizeRedundancy lowercases a reading and turns the punctuation vendors
+// disagree on into single spaces, so "Non-Redundant:Sufficient" and
+// "Non-redundant: Sufficient" compare equal.
+func normalizeRedundancy(reading string) string {
+	reading = strings.NewReplacer("-", " ", ":", " ").Replace(strings.ToLower(reading))
+	return strings.Join(strings.Fields(reading), " ")
+}
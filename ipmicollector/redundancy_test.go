@@ -0,0 +1,50 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFanRedundancy(t *testing.T) {
+	tests := []struct {
+		reading string
+		want    float64
+	}{
+		{"Fully Redundant", 0},
+		{"Redundancy Degraded", 1},
+		{"Redundancy Degraded from Fully Redundant", 1},
+		{"Redundancy Lost", 2},
+		{"Non-Redundant: Sufficient from Redundant", 3},
+		{"Non-redundant:Sufficient", 3},
+		{"Non-Redundant: Insufficient Resources", 4},
+		{"Fully Redundant, Redundancy Lost", 0},
+		{"Unknown state, Redundancy Lost", 2},
+		{"Redundancy State 0x05", unknownRedundancy},
+		{"", unknownRedundancy},
+	}
+	for _, tt := range tests {
+		if got := parseFanRedundancy(tt.reading); got != tt.want {
+			t.Errorf("parseFanRedundancy(%q) = %v, want %v", tt.reading, got, tt.want)
+		}
+	}
+}
+
+func TestParseSensorDataFanRedundancy(t *testing.T) {
+	output := strings.Join([]string{
+		"Fan Redundancy   | 0Ah | ok  | 29.1 | Fully Redundant",
+		"Redundant Fans 2 | 0Bh | ok  | 29.2 | Redundancy Lost",
+		"PS Redundancy    | 0Ch | ok  | 10.1 | Fully Redundant",
+		"Fan Redundancy 3 | 0Dh | ok  | 29.3 | Redundancy State 0x05",
+	}, "\n")
+	got, _, _ := parseSensorData(output, sdrFormats[0], SensorFilter{})
+	// Power supply redundancy is not exported.
+	want := []SensorData{
+		{Name: "Fan Redundancy", ID: "0Ah", Status: "ok", Entity: "29.1", Value: 0, Type: "fan_redundancy"},
+		{Name: "Redundant Fans 2", ID: "0Bh", Status: "ok", Entity: "29.2", Value: 2, Type: "fan_redundancy"},
+		{Name: "Fan Redundancy 3", ID: "0Dh", Status: "ok", Entity: "29.3", Value: unknownRedundancy, Type: "fan_redundancy"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSensorData():\ngot  %+v\nwant %+v", got, want)
+	}
+}
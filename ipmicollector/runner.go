@@ -0,0 +1,137 @@
+package ipmicollector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// CommandRunner executes ipmitool. LocalRunner is used unless WithRunner
+// selects another, such as an SSHRunner; parsing is the same for all.
+type CommandRunner interface {
+	// Run executes ipmitool with args and the password exported as
+	// IPMITOOL_PASSWORD, so that -E picks it up. It returns standard output
+	// and standard error; err is non-nil if the command could not be run or
+	// exited with a non-zero status. Cancelling ctx kills the command.
+	Run(ctx context.Context, args []string, password string) (stdout, stderr []byte, err error)
+}
+
+// DefaultMaxOutputBytes is how much standard output runners capture from a
+// single ipmitool command unless configured otherwise. Real sdr listings are
+// a few kilobytes.
+const DefaultMaxOutputBytes = 10 << 20
+
+// ErrOutputTooLarge is returned by runners when ipmitool prints more than
+// their output limit. The command is killed and its output discarded.
+var ErrOutputTooLarge = errors.New("ipmitool output exceeds the limit")
+
+// limitedBuffer buffers up to limit bytes, or DefaultMaxOutputBytes if limit
+// is not positive, and fails writes past that, so runaway output stops the
+// command instead of exhausting memory.
+// The buffer is not embedded, as its ReadFrom would let io.Copy bypass Write.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	limit := b.limit
+	if limit <= 0 {
+		limit = DefaultMaxOutputBytes
+	}
+	if int64(b.buf.Len())+int64(len(p)) > limit {
+		b.exceeded = true
+		return 0, ErrOutputTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the output buffered so far.
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// err returns an error wrapping ErrOutputTooLarge if the limit was exceeded.
+func (b *limitedBuffer) err() error {
+	if !b.exceeded {
+		return nil
+	}
+	limit := b.limit
+	if limit <= 0 {
+		limit = DefaultMaxOutputBytes
+	}
+	return fmt.Errorf("%w of %d bytes", ErrOutputTooLarge, limit)
+}
+
+// LocalRunner runs ipmitool as a child process.
+type LocalRunner struct {
+	// Path is the ipmitool binary. Empty looks up ipmitool in $PATH.
+	Path string
+	// MaxOutputBytes caps the captured standard output of a command. Zero
+	// uses DefaultMaxOutputBytes.
+	MaxOutputBytes int64
+}
+
+// LookPath resolves the ipmitool binary the runner executes, so a missing
+// installation can be reported once at startup rather than on every command.
+func (r LocalRunner) LookPath() (string, error) {
+	name := r.Path
+	if name == "" {
+		name = "ipmitool"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find ipmitool: %w", err)
+	}
+	return path, nil
+}
+
+// Run implements CommandRunner.
+func (r LocalRunner) Run(ctx context.Context, args []string, password string) ([]byte, []byte, error) {
+	name := r.Path
+	if name == "" {
+		name = "ipmitool"
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	// -E makes ipmitool read the password from IPMITOOL_PASSWORD so it never
+	// shows up in the process list.
+	cmd.Env = append(os.Environ(), "IPMITOOL_PASSWORD="+password)
+	// Don't wait forever on pipes held open by children of a killed ipmitool.
+	cmd.WaitDelay = time.Second
+
+	stdout := limitedBuffer{limit: r.MaxOutputBytes}
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err := stdout.err(); err != nil {
+		return nil, stderr.Bytes(), err
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// FileRunner serves captured `ipmitool sdr elist full` output from the file
+// at Path, so the collector can run without a BMC. The file is read on every
+// collection. Other subcommands report that they are not supported, which
+// the optional collectors already tolerate.
+type FileRunner struct {
+	Path string
+}
+
+// Run implements CommandRunner.
+func (r FileRunner) Run(_ context.Context, args []string, _ string) ([]byte, []byte, error) {
+	if !slices.Contains(args, "sdr") {
+		return nil, []byte("command not supported when reading from a file"), errors.New("no output available")
+	}
+	output, err := os.ReadFile(r.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, nil, nil
+}
@@ -0,0 +1,96 @@
+package ipmicollector
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requireBinary skips the test if name is not installed.
+func requireBinary(t *testing.T, name string) {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s not installed", name)
+	}
+}
+
+func TestLocalRunnerCancel(t *testing.T) {
+	requireBinary(t, "sh")
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "command", script: "exec sleep 30"},
+		// The background sleep keeps stdout open after sh is killed, which
+		// WaitDelay stops waiting for.
+		{name: "child holding stdout", script: "sleep 30 & sleep 30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, _, err := LocalRunner{Path: "sh"}.Run(ctx, []string{"-c", tt.script}, "")
+			if err == nil {
+				t.Error("Run() = nil, want an error for the killed command")
+			}
+			// Killed at the deadline, and waited for no longer than WaitDelay.
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("Run() returned after %v, want the command killed", elapsed)
+			}
+		})
+	}
+}
+
+func TestLocalRunnerMissingBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	runner := LocalRunner{}
+	if _, err := runner.LookPath(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("LookPath() = %v, want exec.ErrNotFound", err)
+	}
+	if _, _, err := runner.Run(context.Background(), []string{"sdr"}, ""); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Run() = %v, want exec.ErrNotFound", err)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := limitedBuffer{limit: 10}
+	if n, err := b.Write([]byte("12345678")); n != 8 || err != nil {
+		t.Fatalf("Write() under the limit = %d, %v", n, err)
+	}
+	if n, err := b.Write([]byte("90a")); n != 0 || !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("Write() past the limit = %d, %v; want 0, ErrOutputTooLarge", n, err)
+	}
+	if got := string(b.Bytes()); got != "12345678" {
+		t.Errorf("Bytes() = %q, want the output up to the limit", got)
+	}
+	if err := b.err(); !errors.Is(err, ErrOutputTooLarge) || !strings.Contains(err.Error(), "of 10 bytes") {
+		t.Errorf("err() = %v, want ErrOutputTooLarge of 10 bytes", err)
+	}
+
+	var unlimited limitedBuffer
+	if _, err := unlimited.Write(make([]byte, DefaultMaxOutputBytes)); err != nil || unlimited.err() != nil {
+		t.Errorf("Write() of DefaultMaxOutputBytes without a limit = %v", err)
+	}
+}
+
+func TestLocalRunnerOutputLimit(t *testing.T) {
+	requireBinary(t, "sh")
+	runner := LocalRunner{Path: "sh", MaxOutputBytes: 1024}
+	stdout, _, err := runner.Run(context.Background(), []string{"-c", "head -c 1000000 /dev/zero"}, "")
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("Run() = %v, want ErrOutputTooLarge", err)
+	}
+	if stdout != nil {
+		t.Errorf("Run() returned %d bytes of output, want it discarded", len(stdout))
+	}
+
+	stdout, _, err = runner.Run(context.Background(), []string{"-c", "head -c 1024 /dev/zero"}, "")
+	if err != nil || len(stdout) != 1024 {
+		t.Errorf("Run() at the limit = %d bytes, %v; want 1024 bytes", len(stdout), err)
+	}
+}
@@ -0,0 +1,187 @@
+package ipmicollector
+
+import (
+	"bufio"
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sdrRow holds the columns ipmitool printed for one sensor. Formats that
+// don't print a column leave it empty.
+type sdrRow struct {
+	name, id, status, entity, value string
+}
+
+// sdrFormat is a layout of ipmitool sensor listings selected with
+// WithSDRFormat, together with the command printing it. rows yields the
+// sensors of a listing as they are read.
+type sdrFormat struct {
+	name string
+	args []string
+	rows func(output string) iter.Seq[sdrRow]
+}
+
+var sdrFormats = []sdrFormat{
+	{"elist", []string{"sdr", "elist", "full"}, elistRows},
+	{"list", []string{"sdr", "list", "full"}, listRows},
+	{"v", []string{"-v", "sdr", "list", "full"}, verboseRows},
+}
+
+// lookupSDRFormat returns the listing format called name.
+func lookupSDRFormat(name string) (sdrFormat, error) {
+	i := slices.IndexFunc(sdrFormats, func(f sdrFormat) bool { return f.name == name })
+	if i < 0 {
+		names := make([]string, len(sdrFormats))
+		for i, f := range sdrFormats {
+			names[i] = f.name
+		}
+		return sdrFormat{}, fmt.Errorf("invalid SDR format %q: must be one of %s", name, strings.Join(names, ", "))
+	}
+	return sdrFormats[i], nil
+}
+
+// ValidateSDRFormat checks that name is a format accepted by WithSDRFormat:
+// elist, list or v.
+func ValidateSDRFormat(name string) error {
+	_, err := lookupSDRFormat(name)
+	return err
+}
+
+// sensorValueStart matches the start of the value of a sensor: a number, a
+// known unit followed by a number, a hex literal, "No Reading", "Disabled", a
+// known discrete state or a redundancy state, so warnings that ipmitool
+// interleaves with the sensor rows are not mistaken for sensors.
+var sensorValueStart = `(?:[-+]?\.?\d|` + leadingUnitPattern() + `|0x[0-9a-fA-F]|No Reading|Disabled|` + discreteStatePattern() + `|` + redundancyPattern + `)`
+
+// sensorRegex matches a line of `ipmitool sdr elist full` output:
+// name | id | status | entity | value.
+var sensorRegex = regexp.MustCompile(`^([^|]+)\s*\|\s*([^|]+)\s*\|\s*(\w+)\s*\|\s*([^|]+)\s*\|\s*(` + sensorValueStart + `.*)$`)
+
+// listSensorRegex matches a line of `ipmitool sdr list full` output:
+// name | value | status.
+var listSensorRegex = regexp.MustCompile(`^([^|]+)\|\s*(` + sensorValueStart + `[^|]*)\|\s*(\w+)\s*$`)
+
+// outputLines yields the lines of output one at a time, read with a
+// bufio.Scanner so listings of thousands of sensors aren't split into a slice
+// of lines up front.
+func outputLines(output string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		// No line is longer than the output, so none is too long to scan.
+		scanner.Buffer(nil, len(output)+1)
+		for scanner.Scan() {
+			if !yield(scanner.Text()) {
+				return
+			}
+		}
+	}
+}
+
+func elistRows(output string) iter.Seq[sdrRow] {
+	return func(yield func(sdrRow) bool) {
+		for line := range outputLines(output) {
+			m := sensorRegex.FindStringSubmatch(strings.TrimSpace(line))
+			if m != nil && !yield(sdrRow{name: m[1], id: m[2], status: m[3], entity: m[4], value: m[5]}) {
+				return
+			}
+		}
+	}
+}
+
+// listRows parses `sdr list`, which prints neither sensor IDs nor entities.
+func listRows(output string) iter.Seq[sdrRow] {
+	return func(yield func(sdrRow) bool) {
+		for line := range outputLines(output) {
+			m := listSensorRegex.FindStringSubmatch(strings.TrimSpace(line))
+			if m != nil && !yield(sdrRow{name: m[1], value: m[2], status: m[3]}) {
+				return
+			}
+		}
+	}
+}
+
+var (
+	// verboseSensorID matches a sensor ID such as "CPU Temp (0x1)".
+	verboseSensorID = regexp.MustCompile(`^(.*?)\s*\(0x([0-9a-fA-F]+)\)$`)
+	// verboseTolerance matches the tolerance in a reading such as
+	// "45 (+/- 0) degrees C".
+	verboseTolerance = regexp.MustCompile(`\s*\(\+/-[^)]*\)`)
+	// verboseValue matches the readings taken for sensors.
+	verboseValue = regexp.MustCompile(`^` + sensorValueStart)
+)
+
+// verboseRows parses `ipmitool -v sdr list full`, which prints a block of
+// "key : value" lines per sensor:
+//
+//	Sensor ID              : CPU Temp (0x1)
+//	 Entity ID             : 3.1 (Processor)
+//	 Sensor Reading        : 45 (+/- 0) degrees C
+//	 Status                : ok
+//
+// Discrete sensors list their asserted states in brackets on the lines
+// following "States Asserted", which become a comma-separated value as in
+// `sdr elist`. IDs are formatted as in `sdr elist`, in lowercase hex, e.g.
+// "c8h", so that a sensor keeps its labels across formats.
+func verboseRows(output string) iter.Seq[sdrRow] {
+	return func(yield func(sdrRow) bool) {
+		var row *sdrRow
+		var states []string
+		inStates := false
+		// finish yields the current row, if it has a reading, and reports
+		// whether to go on.
+		finish := func() bool {
+			current := row
+			if current != nil && current.value == "" && len(states) > 0 {
+				current.value = strings.Join(states, ", ")
+			}
+			row, states, inStates = nil, nil, false
+			return current == nil || !verboseValue.MatchString(current.value) || yield(*current)
+		}
+
+		for line := range outputLines(output) {
+			trimmed := strings.TrimSpace(line)
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				if inStates && strings.HasPrefix(trimmed, "[") {
+					states = append(states, strings.Trim(trimmed, "[]"))
+				}
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			inStates = false
+			if key == "Sensor ID" {
+				if !finish() {
+					return
+				}
+				row = &sdrRow{name: value}
+				if m := verboseSensorID.FindStringSubmatch(value); m != nil {
+					row.name = m[1]
+					if number, err := strconv.ParseUint(m[2], 16, 8); err == nil {
+						row.id = fmt.Sprintf("%02xh", number)
+					}
+				}
+				continue
+			}
+			if row == nil {
+				continue
+			}
+			switch key {
+			case "Entity ID":
+				if fields := strings.Fields(value); len(fields) > 0 {
+					row.entity = fields[0]
+				}
+			case "Sensor Reading":
+				row.value = strings.TrimSpace(verboseTolerance.ReplaceAllString(value, ""))
+			case "Status":
+				row.status = value
+			case "States Asserted":
+				inStates = true
+			}
+		}
+		finish()
+	}
+}
@@ -0,0 +1,101 @@
+package ipmicollector
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateSDRFormat(t *testing.T) {
+	for _, name := range []string{"elist", "list", "v"} {
+		if err := ValidateSDRFormat(name); err != nil {
+			t.Errorf("ValidateSDRFormat(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "full", "ELIST"} {
+		if err := ValidateSDRFormat(name); err == nil {
+			t.Errorf("ValidateSDRFormat(%q) = nil, want an error", name)
+		}
+	}
+}
+
+func TestSDRRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   func(string) []sdrRow
+		output string
+		want   []sdrRow
+	}{
+		{
+			name:   "elist skips warnings",
+			rows:   collectRows(elistRows),
+			output: "Get HPM.x Capabilities request failed, compcode = c9\r\nCPU Temp | 01h | ok | 3.1 | 45 degrees C\r\nSome | odd | line | here | now\n",
+			want:   []sdrRow{{name: "CPU Temp ", id: "01h ", status: "ok", entity: "3.1 ", value: "45 degrees C"}},
+		},
+		{
+			name:   "list",
+			rows:   collectRows(listRows),
+			output: "CPU Temp | 45 degrees C | ok\nWarning: no | reading | here\n",
+			want:   []sdrRow{{name: "CPU Temp ", value: "45 degrees C ", status: "ok"}},
+		},
+		{
+			name: "verbose",
+			rows: collectRows(verboseRows),
+			output: `Sensor ID              : PS1 Status (0xc8)
+ Entity ID             : 10.1 (Power Supply)
+ States Asserted       : Power Supply
+                         [Presence detected]
+                         [Failure detected]
+ Status                : ok
+
+Sensor ID              : Without Reading (0x2)
+ Entity ID             : 7.1 (System Board)
+
+Sensor ID              : Inlet Temp (0x4)
+ Sensor Reading        : 23 (+/- 0.500) degrees C
+`,
+			want: []sdrRow{
+				{name: "PS1 Status", id: "c8h", status: "ok", entity: "10.1", value: "Presence detected, Failure detected"},
+				{name: "Inlet Temp", id: "04h", value: "23 degrees C"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rows(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows:\ngot  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSDRRowsStopEarly(t *testing.T) {
+	output := largeElist(10)
+	var names []string
+	for row := range elistRows(output) {
+		names = append(names, strings.TrimSpace(row.name))
+		if len(names) == 2 {
+			break
+		}
+	}
+	if want := []string{"CPU0 Temp", "Fan1"}; !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestOutputLinesLongLine(t *testing.T) {
+	long := strings.Repeat("x", 200_000)
+	lines := slices.Collect(outputLines("first\n" + long + "\nlast"))
+	if len(lines) != 3 || lines[1] != long || lines[2] != "last" {
+		t.Errorf("got %d lines, want first, the long line and last", len(lines))
+	}
+}
+
+// collectRows returns a function collecting the rows yielded by rows.
+func collectRows(rows func(string) iter.Seq[sdrRow]) func(string) []sdrRow {
+	return func(output string) []sdrRow {
+		return slices.Collect(rows(output))
+	}
+}
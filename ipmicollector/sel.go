@@ -0,0 +1,62 @@
+package ipmicollector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selInfo summarizes the System Event Log of a BMC.
+type selInfo struct {
+	Entries          float64
+	FreeSpacePercent float64
+}
+
+// collectSELInfo reads the SEL summary of the BMC. `sel info` reports the entry
+// count directly, so the potentially large `sel elist` is not needed.
+func (c *Collector) collectSELInfo(ctx context.Context) (*selInfo, error) {
+	output, err := c.executeIPMICommand(ctx, "sel", "info")
+	if err != nil {
+		return nil, err
+	}
+	return parseSELInfo(output)
+}
+
+// parseSELInfo parses `ipmitool sel info` output such as
+//
+//	Entries          : 52
+//	Percent Used     : 5%
+func parseSELInfo(output string) (*selInfo, error) {
+	var (
+		info                   selInfo
+		haveEntries, haveUsage bool
+	)
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Entries":
+			if entries, err := strconv.ParseFloat(value, 64); err == nil {
+				info.Entries = entries
+				haveEntries = true
+			}
+		case "Percent Used":
+			// Reported as "unknown" by BMCs that don't track usage.
+			if used, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+				info.FreeSpacePercent = 100 - used
+				haveUsage = true
+			}
+		}
+	}
+
+	if !haveEntries || !haveUsage {
+		return nil, fmt.Errorf("unexpected sel info output")
+	}
+	return &info, nil
+}
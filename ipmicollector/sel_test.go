@@ -0,0 +1,50 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSELInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *selInfo
+		wantErr bool
+	}{
+		{
+			name: "in use",
+			output: `SEL Information
+Version          : 1.5 (v1.5, v2 compliant)
+Entries          : 52
+Free Space       : 15488 bytes
+Percent Used     : 5%
+Last Add Time    : 01/02/2024 10:11:12
+`,
+			want: &selInfo{Entries: 52, FreeSpacePercent: 95},
+		},
+		{
+			name:   "empty",
+			output: "Entries          : 0\nPercent Used     : 0%\n",
+			want:   &selInfo{Entries: 0, FreeSpacePercent: 100},
+		},
+		{
+			name:    "usage unknown",
+			output:  "Entries          : 12\nPercent Used     : unknown\n",
+			wantErr: true,
+		},
+		{
+			name:    "unexpected output",
+			output:  "Error: unable to get SEL info\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSELInfo(tt.output)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSELInfo() = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package ipmicollector
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SensorData is a sensor reading parsed from `ipmitool sdr elist full`.
+type SensorData struct {
+	Name   string
+	ID     string
+	Status string
+	Entity string
+	Value  float64
+	Unit   string
+	Type   string
+	// NoReading is set for sensors reported as "No Reading" or "Disabled",
+	// which carry no value.
+	NoReading bool
+	// States holds the asserted states of a discrete sensor.
+	States []string
+	// FanID is the fan a fan sensor belongs to, shared by the RPM and duty
+	// sensors of one physical fan.
+	FanID string
+	// Direction is "input" or "output" for power supply power sensors and
+	// empty otherwise.
+	Direction string
+	// Thresholds maps a threshold level such as "upper_critical" to its value,
+	// in the same unit as Value.
+	Thresholds map[string]float64
+}
+
+// parseSensorData parses a sensor listing printed in format, keeping only
+// the sensors allowed by filter. It also returns the number of sensor lines
+// whose value could not be parsed and the number dropped by filter.
+func parseSensorData(sdrData string, format sdrFormat, filter SensorFilter) (sensors []SensorData, parseErrors, filtered int) {
+	for row := range format.rows(sdrData) {
+		name := strings.TrimSpace(row.name)
+		id := strings.TrimSpace(row.id)
+		status := strings.TrimSpace(row.status)
+		entity := strings.TrimSpace(row.entity)
+		valueStr := strings.TrimSpace(row.value)
+
+		if !filter.allows(name) {
+			filtered++
+			continue
+		}
+
+		if strings.Contains(valueStr, "No Reading") || strings.EqualFold(valueStr, "Disabled") {
+			// Keep the sensor so an empty slot or disabled sensor can be
+			// told apart from a failed collection.
+			sensors = append(sensors, SensorData{
+				Name:      name,
+				ID:        id,
+				Status:    status,
+				Entity:    entity,
+				NoReading: true,
+			})
+			continue
+		}
+
+		if fanRedundancySensor.MatchString(name) {
+			sensors = append(sensors, SensorData{
+				Name:   name,
+				ID:     id,
+				Status: status,
+				Entity: entity,
+				Value:  parseFanRedundancy(valueStr),
+				Type:   "fan_redundancy",
+			})
+			continue
+		}
+		if redundancyReading.MatchString(valueStr) {
+			// Redundancy of other resources, such as power supplies, is not
+			// exported.
+			continue
+		}
+
+		if sensorType, states, ok := parseDiscreteState(name, valueStr); ok {
+			sensors = append(sensors, SensorData{
+				Name:   name,
+				ID:     id,
+				Status: status,
+				Entity: entity,
+				Type:   sensorType,
+				States: states,
+			})
+			continue
+		}
+
+		if raw, ok := parseRawValue(valueStr); ok {
+			sensors = append(sensors, SensorData{
+				Name:   name,
+				ID:     id,
+				Status: status,
+				Entity: entity,
+				Value:  raw,
+				Type:   "raw",
+			})
+			continue
+		}
+
+		value, unit, sensorType, ok := parseValue(valueStr)
+		if !ok {
+			slog.Debug("Skipping sensor with unrecognized value", "sensor", name, "value", valueStr)
+			parseErrors++
+			continue
+		}
+		// Percent readings are ambiguous; a fan sensor reporting one gives
+		// its duty cycle rather than RPM, a humidity sensor the relative
+		// humidity.
+		if sensorType == "percent" {
+			switch {
+			case fanSensorName.MatchString(name):
+				sensorType = "fan_percent"
+			case humiditySensorName.MatchString(name):
+				sensorType = "humidity"
+			}
+		}
+		var direction, fan string
+		switch sensorType {
+		case "power":
+			direction = powerDirection(name)
+		case "fan", "fan_percent":
+			fan = fanID(name)
+		}
+
+		sensors = append(sensors, SensorData{
+			Name:      name,
+			ID:        id,
+			Status:    status,
+			Entity:    entity,
+			Value:     value,
+			Unit:      unit,
+			Type:      sensorType,
+			FanID:     fan,
+			Direction: direction,
+		})
+	}
+
+	disambiguateSensorIDs(sensors)
+	return sensors, parseErrors, filtered
+}
+
+// disambiguateSensorIDs rewrites the IDs of sensors that share both name and
+// ID with another sensor, which some BMCs report for every sensor of a kind.
+// The entity is appended first and, if that is not enough, the row index, so
+// each physical sensor keeps its own series instead of failing the scrape
+// with duplicate label values.
+func disambiguateSensorIDs(sensors []SensorData) {
+	type sensorKey struct{ name, id string }
+	key := func(s SensorData) sensorKey { return sensorKey{s.Name, s.ID} }
+
+	counts := make(map[sensorKey]int, len(sensors))
+	duplicates := false
+	for _, sensor := range sensors {
+		counts[key(sensor)]++
+		duplicates = duplicates || counts[key(sensor)] > 1
+	}
+	if !duplicates {
+		return
+	}
+
+	for i := range sensors {
+		if counts[key(sensors[i])] > 1 && sensors[i].Entity != "" {
+			sensors[i].ID += "/" + sensors[i].Entity
+		}
+	}
+	seen := make(map[sensorKey]bool, len(sensors))
+	for i := range sensors {
+		k := key(sensors[i])
+		if seen[k] {
+			sensors[i].ID += "/" + strconv.Itoa(i)
+		}
+		seen[k] = true
+	}
+}
+
+// fanSensorName matches the names BMCs give to fan sensors.
+var fanSensorName = regexp.MustCompile(`(?i)fan`)
+
+// humiditySensorName matches the names BMCs give to humidity sensors.
+var humiditySensorName = regexp.MustCompile(`(?i)humid`)
+
+// fanSuffix matches the measurement suffix of a fan sensor name.
+var fanSuffix = regexp.MustCompile(`(?i)[\s_-]*(rpm|duty|speed)$`)
+
+// fanID strips measurement suffixes such as "RPM" or "Duty" from a fan
+// sensor name, so "Fan1 RPM" and "Fan1 Duty" both become "Fan1".
+func fanID(name string) string {
+	for {
+		loc := fanSuffix.FindStringIndex(name)
+		if loc == nil || loc[0] == 0 {
+			return name
+		}
+		name = name[:loc[0]]
+	}
+}
+
+// powerDirection returns "input" or "output" when the power sensor name says
+// which side of a power supply it measures, e.g. "PS1 Input Power".
+func powerDirection(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "input"):
+		return "input"
+	case strings.Contains(lower, "output"):
+		return "output"
+	}
+	return ""
+}
+
+// thousandsSeparated matches numbers such as "10,400" or "1,234.5".
+var thousandsSeparated = regexp.MustCompile(`^[-+]?\d{1,3}(,\d{3})+(\.\d+)?$`)
+
+// parseNumber parses a reading that may use a comma as thousands separator
+// ("10,400") or, as printed by some localized builds, as decimal separator
+// ("12,05"). Scientific notation such as "1.2e-01" is accepted; NaN and
+// infinities are not.
+func parseNumber(s string) (float64, error) {
+	switch {
+	case thousandsSeparated.MatchString(s):
+		s = strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ",") == 1 && !strings.Contains(s, "."):
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("non-finite reading %q", s)
+	}
+	return value, nil
+}
+
+// parseRawValue decodes a hex literal such as "0x0180", which BMCs print for
+// sensors without a unit.
+func parseRawValue(valueStr string) (float64, bool) {
+	digits, found := strings.CutPrefix(strings.ToLower(valueStr), "0x")
+	if !found {
+		return 0, false
+	}
+	raw, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float64(raw), true
+}
+
+// valueUnit is the exported unit and sensor type of an ipmitool unit.
+type valueUnit struct {
+	unit       string
+	sensorType string
+}
+
+// valueUnits maps the trailing unit of an ipmitool reading to the exported
+// unit and sensor type. Temperatures are printed as "degrees C" by most
+// builds and as "C" or "°C" by some locales and -v output.
+var valueUnits = map[string]valueUnit{
+	"Volts":     {"volts", "voltage"},
+	"degrees C": {"celsius", "temperature"},
+	"C":         {"celsius", "temperature"},
+	"°C":        {"celsius", "temperature"},
+	"RPM":       {"rpm", "fan"},
+	"Watts":     {"watts", "power"},
+	"Amps":      {"amperes", "current"},
+	"percent":   {"percent", "percent"},
+	"%":         {"percent", "percent"},
+	"CFM":       {"cfm", "airflow"},
+	"Joules":    {"joules", "energy"},
+}
+
+// unitsByLength lists the keys of valueUnits longest first, so "45 degrees C"
+// is split at "degrees C" rather than at "C".
+var unitsByLength = func() []string {
+	units := make([]string, 0, len(valueUnits))
+	for unit := range valueUnits {
+		units = append(units, unit)
+	}
+	slices.SortFunc(units, func(a, b string) int {
+		return cmp.Or(len(b)-len(a), strings.Compare(a, b))
+	})
+	return units
+}()
+
+// gluedUnits are the units that may directly follow the number, as in "45%"
+// or "45°C".
+var gluedUnits = []string{"%", "°C"}
+
+// leadingUnitPattern returns a regular expression matching a known unit
+// followed by a number, as printed by builds that put the unit first.
+func leadingUnitPattern() string {
+	units := make([]string, 0, len(valueUnits))
+	for unit := range valueUnits {
+		units = append(units, regexp.QuoteMeta(unit))
+	}
+	slices.Sort(units)
+	return `(?:` + strings.Join(units, "|") + `)\s+[-+]?\.?\d`
+}
+
+// parseValue extracts the numeric reading, its unit and the sensor type from
+// an ipmitool value column. ok is false when the value could not be parsed,
+// which keeps a legitimate zero reading apart from a parse failure. The unit
+// must be the leading or trailing token and everything else the number, so
+// text that merely contains a unit name is not mistaken for a reading.
+func parseValue(valueStr string) (value float64, unit, sensorType string, ok bool) {
+	number, suffix := splitUnit(valueStr)
+	u, known := valueUnits[suffix]
+	if !known {
+		return 0, "", "", false
+	}
+	val, err := parseNumber(number)
+	if err != nil {
+		return 0, "", "", false
+	}
+	return val, u.unit, u.sensorType, true
+}
+
+// splitUnit splits a reading such as "12.05 Volts (DC)", or "Volts 12.05" as
+// printed by some localized builds, into its number and unit. Trailing
+// qualifiers in parentheses, which some BMCs append after the unit, are
+// dropped. unit is empty if no known unit leads or trails the number.
+func splitUnit(valueStr string) (number, unit string) {
+	valueStr = strings.TrimSpace(valueStr)
+	if strings.HasSuffix(valueStr, ")") {
+		if i := strings.LastIndexByte(valueStr, '('); i >= 0 {
+			valueStr = strings.TrimSpace(valueStr[:i])
+		}
+	}
+
+	for _, unit := range gluedUnits {
+		if number, found := strings.CutSuffix(valueStr, unit); found {
+			return strings.TrimSpace(number), unit
+		}
+	}
+	for _, unit := range unitsByLength {
+		if number, found := strings.CutSuffix(valueStr, " "+unit); found {
+			return strings.TrimSpace(number), unit
+		}
+		if number, found := strings.CutPrefix(valueStr, unit+" "); found {
+			return strings.TrimSpace(number), unit
+		}
+	}
+	return valueStr, ""
+}
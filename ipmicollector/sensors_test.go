@@ -0,0 +1,228 @@
+package ipmicollector
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in         string
+		value      float64
+		unit       string
+		sensorType string
+		ok         bool
+	}{
+		{"45 degrees C", 45, "celsius", "temperature", true},
+		{"45 C", 45, "celsius", "temperature", true},
+		{"45°C", 45, "celsius", "temperature", true},
+		{"12.05 Volts", 12.05, "volts", "voltage", true},
+		{"Volts 12.05", 12.05, "volts", "voltage", true},
+		{"12.05 Volts (DC)", 12.05, "volts", "voltage", true},
+		{"5400 RPM", 5400, "rpm", "fan", true},
+		{"10,400 RPM", 10400, "rpm", "fan", true},
+		{"220 Watts", 220, "watts", "power", true},
+		{"1.2 Amps", 1.2, "amperes", "current", true},
+		{"40 percent", 40, "percent", "percent", true},
+		{"40%", 40, "percent", "percent", true},
+		{"30 CFM", 30, "cfm", "airflow", true},
+		{"1500 Joules", 1500, "joules", "energy", true},
+		{"-3 degrees C", -3, "celsius", "temperature", true},
+		{"12 furlongs", 0, "", "", false},
+		{"Volts", 0, "", "", false},
+		{"abc Volts", 0, "", "", false},
+		{"NaN Volts", 0, "", "", false},
+		{"", 0, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			value, unit, sensorType, ok := parseValue(tt.in)
+			if value != tt.value || unit != tt.unit || sensorType != tt.sensorType || ok != tt.ok {
+				t.Errorf("parseValue(%q) = %v, %q, %q, %v; want %v, %q, %q, %v", tt.in, value, unit, sensorType, ok, tt.value, tt.unit, tt.sensorType, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"12.05", 12.05, false},
+		{"10,400", 10400, false},
+		{"1,234.5", 1234.5, false},
+		{"12,05", 12.05, false},
+		{"1.2e-01", 0.12, false},
+		{"-7", -7, false},
+		{"+Inf", 0, true},
+		{"NaN", 0, true},
+		{"1,2,3", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseNumber(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseNumber(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSensorData(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		format      string
+		filter      SensorFilter
+		want        []SensorData
+		parseErrors int
+		filtered    int
+	}{
+		{
+			name:    "elist",
+			fixture: "testdata/sdr_elist.txt",
+			format:  "elist",
+			want: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Status: "ok", Entity: "3.1", Value: 45, Unit: "celsius", Type: "temperature"},
+				{Name: "Inlet Temp", ID: "04h", Status: "ok", Entity: "7.1", Value: 23, Unit: "celsius", Type: "temperature"},
+				{Name: "Fan1 RPM", ID: "30h", Status: "ok", Entity: "29.1", Value: 5400, Unit: "rpm", Type: "fan", FanID: "Fan1"},
+				{Name: "Fan1 Duty", ID: "31h", Status: "ok", Entity: "29.1", Value: 40, Unit: "percent", Type: "fan_percent", FanID: "Fan1"},
+				{Name: "12V", ID: "20h", Status: "ok", Entity: "7.1", Value: 12.05, Unit: "volts", Type: "voltage"},
+				{Name: "PS1 Input Power", ID: "70h", Status: "ok", Entity: "10.1", Value: 220, Unit: "watts", Type: "power", Direction: "input"},
+				{Name: "PS1 Current", ID: "71h", Status: "ok", Entity: "10.1", Value: 1.2, Unit: "amperes", Type: "current"},
+				{Name: "Humidity", ID: "72h", Status: "ok", Entity: "7.1", Value: 35, Unit: "percent", Type: "humidity"},
+				{Name: "Disk 3", ID: "80h", Status: "ns", Entity: "4.3", NoReading: true},
+				{Name: "PS1 Status", ID: "c8h", Status: "ok", Entity: "10.1", Type: "power_supply", States: []string{"presence_detected", "ac_lost"}},
+				{Name: "OEM Raw", ID: "d0h", Status: "ok", Entity: "7.1", Value: 384, Type: "raw"},
+			},
+			parseErrors: 1,
+		},
+		{
+			name:    "elist filtered",
+			fixture: "testdata/sdr_elist.txt",
+			format:  "elist",
+			filter:  mustSensorFilter(t, "", "^(Fan|PS1|Bad)"),
+			want: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Status: "ok", Entity: "3.1", Value: 45, Unit: "celsius", Type: "temperature"},
+				{Name: "Inlet Temp", ID: "04h", Status: "ok", Entity: "7.1", Value: 23, Unit: "celsius", Type: "temperature"},
+				{Name: "12V", ID: "20h", Status: "ok", Entity: "7.1", Value: 12.05, Unit: "volts", Type: "voltage"},
+				{Name: "Humidity", ID: "72h", Status: "ok", Entity: "7.1", Value: 35, Unit: "percent", Type: "humidity"},
+				{Name: "Disk 3", ID: "80h", Status: "ns", Entity: "4.3", NoReading: true},
+				{Name: "OEM Raw", ID: "d0h", Status: "ok", Entity: "7.1", Value: 384, Type: "raw"},
+			},
+			filtered: 6,
+		},
+		{
+			// Inlet Temp and Fan1 Duty match both patterns, and exclude wins.
+			name:    "elist included and excluded",
+			fixture: "testdata/sdr_elist.txt",
+			format:  "elist",
+			filter:  mustSensorFilter(t, "Temp|Fan", "Inlet|Duty"),
+			want: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Status: "ok", Entity: "3.1", Value: 45, Unit: "celsius", Type: "temperature"},
+				{Name: "Fan1 RPM", ID: "30h", Status: "ok", Entity: "29.1", Value: 5400, Unit: "rpm", Type: "fan", FanID: "Fan1"},
+			},
+			filtered: 10,
+		},
+		{
+			name:    "list",
+			fixture: "testdata/sdr_list.txt",
+			format:  "list",
+			want: []SensorData{
+				{Name: "CPU Temp", Status: "ok", Value: 45, Unit: "celsius", Type: "temperature"},
+				{Name: "Fan1 RPM", Status: "ok", Value: 5400, Unit: "rpm", Type: "fan", FanID: "Fan1"},
+				{Name: "12V", Status: "ok", Value: 12.05, Unit: "volts", Type: "voltage"},
+				{Name: "Disk 3", Status: "ns", NoReading: true},
+			},
+		},
+		{
+			name:    "verbose",
+			fixture: "testdata/sdr_verbose.txt",
+			format:  "v",
+			want: []SensorData{
+				{Name: "CPU Temp", ID: "01h", Status: "ok", Entity: "3.1", Value: 45, Unit: "celsius", Type: "temperature"},
+				{Name: "PS1 Status", ID: "c8h", Entity: "10.1", Type: "power_supply", States: []string{"presence_detected", "ac_lost"}},
+				{Name: "Fan1", ID: "30h", Status: "ok", Entity: "29.1", Value: 5400, Unit: "rpm", Type: "fan", FanID: "Fan1"},
+				{Name: "Broken", ID: "31h", Status: "ns", Entity: "29.2", NoReading: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := os.ReadFile(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			format, err := lookupSDRFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, parseErrors, filtered := parseSensorData(string(output), format, tt.filter)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sensors:\ngot  %+v\nwant %+v", got, tt.want)
+			}
+			if parseErrors != tt.parseErrors || filtered != tt.filtered {
+				t.Errorf("parse errors, filtered = %d, %d; want %d, %d", parseErrors, filtered, tt.parseErrors, tt.filtered)
+			}
+		})
+	}
+}
+
+func TestDisambiguateSensorIDs(t *testing.T) {
+	sensors := []SensorData{
+		{Name: "Temp", ID: "00h", Entity: "3.1"},
+		{Name: "Temp", ID: "00h", Entity: "3.2"},
+		{Name: "Temp", ID: "00h", Entity: "3.2"},
+		{Name: "Fan", ID: "01h", Entity: "29.1"},
+	}
+	disambiguateSensorIDs(sensors)
+	var ids []string
+	for _, sensor := range sensors {
+		ids = append(ids, sensor.ID)
+	}
+	want := []string{"00h/3.1", "00h/3.2", "00h/3.2/2", "01h"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("IDs = %v, want %v", ids, want)
+	}
+}
+
+func mustSensorFilter(t *testing.T, include, exclude string) SensorFilter {
+	t.Helper()
+	filter, err := NewSensorFilter(include, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+// largeElist returns `sdr elist full` output of n sensors, cycling through
+// the kinds of rows a large chassis prints.
+func largeElist(n int) string {
+	rows := []string{
+		"CPU%d Temp        | %02Xh | ok  |  3.1 | 45 degrees C",
+		"Fan%d             | %02Xh | ok  | 29.1 | 5400 RPM",
+		"P12V_%d           | %02Xh | ok  |  7.1 | 12.05 Volts",
+		"PS%d Status       | %02Xh | ok  | 10.1 | Presence detected",
+		"Disk%d            | %02Xh | ns  |  4.1 | No Reading",
+	}
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, rows[i%len(rows)]+"\n", i, i%256)
+	}
+	return b.String()
+}
+
+func BenchmarkParseSensorData(b *testing.B) {
+	output := largeElist(5000)
+	b.SetBytes(int64(len(output)))
+	b.ReportAllocs()
+	for b.Loop() {
+		parseSensorData(output, sdrFormats[0], SensorFilter{})
+	}
+}
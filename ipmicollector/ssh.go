@@ -0,0 +1,150 @@
+package ipmicollector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHRunner runs ipmitool on a relay host over SSH, for BMCs that are only
+// reachable from an isolated management network. One connection is shared by
+// all commands and re-established when it breaks.
+type SSHRunner struct {
+	// MaxOutputBytes caps the captured standard output of a command. Zero
+	// uses DefaultMaxOutputBytes.
+	MaxOutputBytes int64
+
+	address string
+	config  *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewSSHRunner returns a runner for relay, given as user@host[:port],
+// authenticating with the private key at keyFile and verifying the relay
+// against knownHostsFile, or ~/.ssh/known_hosts if empty. Connecting to the
+// relay may take up to dialTimeout.
+func NewSSHRunner(relay, keyFile, knownHostsFile string, dialTimeout time.Duration) (*SSHRunner, error) {
+	user, host, ok := strings.Cut(relay, "@")
+	if !ok || user == "" || host == "" {
+		return nil, fmt.Errorf("invalid relay %q: must be user@host[:port]", relay)
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+
+	if keyFile == "" {
+		return nil, fmt.Errorf("an SSH private key is required for the relay")
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %v", err)
+	}
+
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %v", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts: %v", err)
+	}
+
+	return &SSHRunner{
+		address: host,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         dialTimeout,
+		},
+	}, nil
+}
+
+// Run implements CommandRunner. The password is sent on standard input and
+// exported by the remote shell, so it appears neither in the remote command
+// line nor in an SSH environment request.
+func (r *SSHRunner) Run(ctx context.Context, args []string, password string) ([]byte, []byte, error) {
+	session, err := r.newSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = session.Close() }()
+
+	stdout := limitedBuffer{limit: r.MaxOutputBytes}
+	var stderr bytes.Buffer
+	session.Stdin = strings.NewReader(password + "\n")
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(remoteCommand(args)) }()
+
+	select {
+	case err := <-done:
+		if err := stdout.err(); err != nil {
+			return nil, stderr.Bytes(), err
+		}
+		return stdout.Bytes(), stderr.Bytes(), err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		// session.Run may still be writing to the buffers, and waiting for
+		// it could block on a dead relay, so no output is returned.
+		return nil, nil, ctx.Err()
+	}
+}
+
+// newSession opens a session on the shared connection, dialling the relay
+// again if the connection is missing or broken.
+func (r *SSHRunner) newSession() (*ssh.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client != nil {
+		session, err := r.client.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		_ = r.client.Close()
+		r.client = nil
+	}
+
+	client, err := ssh.Dial("tcp", r.address, r.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH relay %s: %v", r.address, err)
+	}
+	r.client = client
+	return client.NewSession()
+}
+
+// remoteCommand builds the shell command run on the relay for ipmitool args.
+func remoteCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return "IFS= read -r IPMITOOL_PASSWORD && export IPMITOOL_PASSWORD && exec ipmitool " + strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
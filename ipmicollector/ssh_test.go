@@ -0,0 +1,222 @@
+package ipmicollector
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshRelay is an in-process SSH server standing in for a relay. Instead of
+// running the command through a shell it records it and the line read from
+// standard input, and replies with output, or never if the command runs
+// "hang".
+type sshRelay struct {
+	address string
+	output  string
+
+	mu       sync.Mutex
+	commands []string
+	stdin    []string
+}
+
+// startSSHRelay starts a relay accepting the public key of clientKey and
+// returns it with a known_hosts file listing its host key.
+func startSSHRelay(t *testing.T, clientKey ssh.PublicKey, output string) (*sshRelay, string) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	relay := &sshRelay{address: listener.Addr().String(), output: output}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go relay.serve(conn, config)
+		}
+	}()
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{relay.address}, hostSigner.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return relay, knownHosts
+}
+
+func (r *sshRelay) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go r.session(channel, requests)
+	}
+}
+
+func (r *sshRelay) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() { _ = channel.Close() }()
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+			_ = req.Reply(false, nil)
+			return
+		}
+		_ = req.Reply(true, nil)
+		line, _ := bufio.NewReader(channel).ReadString('\n')
+		r.mu.Lock()
+		r.commands = append(r.commands, exec.Command)
+		r.stdin = append(r.stdin, line)
+		r.mu.Unlock()
+
+		if strings.Contains(exec.Command, "'hang'") {
+			// Wait for the client to kill the session.
+			for range requests {
+			}
+			return
+		}
+		_, _ = channel.Write([]byte(r.output))
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		return
+	}
+}
+
+// newTestSSHRunner returns an SSHRunner connecting as ipmi to a relay
+// replying with output.
+func newTestSSHRunner(t *testing.T, output string) (*SSHRunner, *sshRelay) {
+	t.Helper()
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay, knownHosts := startSSHRelay(t, signer.PublicKey(), output)
+	runner, err := NewSSHRunner("ipmi@"+relay.address, keyFile, knownHosts, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runner, relay
+}
+
+func TestSSHRunner(t *testing.T) {
+	const output = "CPU Temp | 01h | ok | 3.1 | 45 degrees C\n"
+	runner, relay := newTestSSHRunner(t, output)
+
+	args := []string{"-I", "lanplus", "-H", "10.0.0.10", "-U", "admin", "-E", "sdr", "elist", "full"}
+	for range 2 {
+		stdout, _, err := runner.Run(context.Background(), args, "s3cr'et")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stdout) != output {
+			t.Errorf("stdout = %q, want %q", stdout, output)
+		}
+	}
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	want := "IFS= read -r IPMITOOL_PASSWORD && export IPMITOOL_PASSWORD && exec ipmitool '-I' 'lanplus' '-H' '10.0.0.10' '-U' 'admin' '-E' 'sdr' 'elist' 'full'"
+	for i, command := range relay.commands {
+		if command != want {
+			t.Errorf("command %d = %q, want %q", i, command, want)
+		}
+		if strings.Contains(command, "s3cr") {
+			t.Errorf("command %d carries the password: %q", i, command)
+		}
+		if relay.stdin[i] != "s3cr'et\n" {
+			t.Errorf("stdin %d = %q, want the password", i, relay.stdin[i])
+		}
+	}
+	if len(relay.commands) != 2 {
+		t.Errorf("relay ran %d commands, want 2", len(relay.commands))
+	}
+}
+
+func TestSSHRunnerCancel(t *testing.T) {
+	runner, _ := newTestSSHRunner(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stdout, stderr, err := runner.Run(ctx, []string{"hang"}, "secret")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() = %v, want context.DeadlineExceeded", err)
+	}
+	if stdout != nil || stderr != nil {
+		t.Errorf("Run() = %q, %q; want no output", stdout, stderr)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run() returned after %v, want it to give up at the deadline", elapsed)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"sdr", "'sdr'"},
+		{"", "''"},
+		{"it's", `'it'\''s'`},
+		{"$(reboot); `id`", "'$(reboot); `id`'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
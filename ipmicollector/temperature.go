@@ -0,0 +1,40 @@
+package ipmicollector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// temperatureUnit converts the celsius readings reported by ipmitool into
+// the unit selected with WithTemperatureUnit.
+type temperatureUnit struct {
+	name    string
+	convert func(celsius float64) float64
+}
+
+var temperatureUnits = []temperatureUnit{
+	{"celsius", func(c float64) float64 { return c }},
+	{"fahrenheit", func(c float64) float64 { return c*9/5 + 32 }},
+	{"kelvin", func(c float64) float64 { return c + 273.15 }},
+}
+
+// lookupTemperatureUnit returns the temperature unit called name.
+func lookupTemperatureUnit(name string) (temperatureUnit, error) {
+	i := slices.IndexFunc(temperatureUnits, func(u temperatureUnit) bool { return u.name == name })
+	if i < 0 {
+		names := make([]string, len(temperatureUnits))
+		for i, u := range temperatureUnits {
+			names[i] = u.name
+		}
+		return temperatureUnit{}, fmt.Errorf("invalid temperature unit %q: must be one of %s", name, strings.Join(names, ", "))
+	}
+	return temperatureUnits[i], nil
+}
+
+// ValidateTemperatureUnit checks that name is a unit accepted by
+// WithTemperatureUnit: celsius, fahrenheit or kelvin.
+func ValidateTemperatureUnit(name string) error {
+	_, err := lookupTemperatureUnit(name)
+	return err
+}
@@ -0,0 +1,72 @@
+package ipmicollector
+
+import (
+	"strings"
+)
+
+// thresholdColumns maps the threshold columns of `ipmitool sensor` output to
+// the level label they are exported with. Non-recoverable thresholds are not
+// exported.
+var thresholdColumns = map[int]string{
+	5: "lower_critical",
+	6: "lower_non_critical",
+	7: "upper_non_critical",
+	8: "upper_critical",
+}
+
+// unsetThreshold reports whether a threshold column holds one of the tokens
+// ipmitool prints for thresholds the BMC doesn't define, such as "na" or
+// "disabled", rather than a value.
+func unsetThreshold(field string) bool {
+	switch strings.ToLower(field) {
+	case "", "na", "n/a", "disabled", "unspecified":
+		return true
+	}
+	return false
+}
+
+// parseSensorThresholds parses `ipmitool sensor` output into a map from sensor
+// name to populated thresholds. The output has the columns name, reading,
+// unit, status, lnr, lcr, lnc, unc, ucr and unr. Unset thresholds are left
+// out.
+func parseSensorThresholds(sensorData string) map[string]map[string]float64 {
+	thresholds := make(map[string]map[string]float64)
+
+	for _, line := range strings.Split(sensorData, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 10 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		name, unit := fields[0], fields[2]
+		for column, level := range thresholdColumns {
+			if unsetThreshold(fields[column]) {
+				continue
+			}
+			value, _, _, ok := parseValue(fields[column] + " " + unit)
+			if !ok {
+				continue
+			}
+			if thresholds[name] == nil {
+				thresholds[name] = make(map[string]float64)
+			}
+			thresholds[name][level] = value
+		}
+	}
+
+	return thresholds
+}
+
+// applyThresholds attaches parsed thresholds to the sensors with a matching
+// name. `ipmitool sensor` does not print sensor IDs, so names are the only
+// join key.
+func applyThresholds(sensors []SensorData, thresholds map[string]map[string]float64) {
+	for i := range sensors {
+		if t, ok := thresholds[sensors[i].Name]; ok {
+			sensors[i].Thresholds = t
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSensorThresholds(t *testing.T) {
+	output := `CPU Temp         | 45.000     | degrees C  | ok    | na        | na        | na        | 85.000    | 90.000    | 95.000
+12V              | 12.050     | Volts      | ok    | 10.200    | 10.800    | 11.400    | 12.600    | 13.200    | 13.800
+Fan1 RPM         | 5400.000   | RPM        | ok    | na        | 300.000   | 500.000   | disabled  | N/A       | na
+PS1 Status       | 0x1        | discrete   | 0x0100| na        | na        | na        | na        | na        | na
+Short line       | 1.000      | Volts      | ok
+`
+	want := map[string]map[string]float64{
+		"CPU Temp": {"upper_non_critical": 85, "upper_critical": 90},
+		"12V":      {"lower_critical": 10.8, "lower_non_critical": 11.4, "upper_non_critical": 12.6, "upper_critical": 13.2},
+		"Fan1 RPM": {"lower_critical": 300, "lower_non_critical": 500},
+	}
+	if got := parseSensorThresholds(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSensorThresholds() = %v, want %v", got, want)
+	}
+}
+
+func TestUnsetThreshold(t *testing.T) {
+	for _, field := range []string{"", "na", "NA", "n/a", "N/A", "disabled", "Unspecified"} {
+		if !unsetThreshold(field) {
+			t.Errorf("unsetThreshold(%q) = false, want true", field)
+		}
+	}
+	for _, field := range []string{"0.000", "85.000", "nan"} {
+		if unsetThreshold(field) {
+			t.Errorf("unsetThreshold(%q) = true, want false", field)
+		}
+	}
+}
+
+func TestApplyThresholds(t *testing.T) {
+	sensors := []SensorData{{Name: "CPU Temp"}, {Name: "Inlet Temp"}}
+	applyThresholds(sensors, map[string]map[string]float64{
+		"CPU Temp": {"upper_critical": 90},
+		"Unknown":  {"upper_critical": 1},
+	})
+	if want := map[string]float64{"upper_critical": 90}; !reflect.DeepEqual(sensors[0].Thresholds, want) {
+		t.Errorf("CPU Temp thresholds = %v, want %v", sensors[0].Thresholds, want)
+	}
+	if sensors[1].Thresholds != nil {
+		t.Errorf("Inlet Temp thresholds = %v, want none", sensors[1].Thresholds)
+	}
+}
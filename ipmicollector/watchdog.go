@@ -0,0 +1,100 @@
+package ipmicollector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// watchdogStatus is the BMC watchdog timer state reported by `ipmitool mc
+// watchdog get`.
+type watchdogStatus struct {
+	Running bool
+	// Action is what the BMC does when the timer expires, such as
+	// "hard_reset", and "no_action" for a watchdog that isn't configured.
+	Action string
+	// InitialCountdown and PresentCountdown are in seconds.
+	InitialCountdown float64
+	PresentCountdown float64
+}
+
+// collectWatchdog reads the watchdog timer of the BMC. BMCs that don't
+// implement the command yield no status and no error.
+func (c *Collector) collectWatchdog(ctx context.Context) (*watchdogStatus, error) {
+	output, err := c.executeIPMICommand(ctx, "mc", "watchdog", "get")
+	if err != nil {
+		if isUnsupportedError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseWatchdog(output)
+}
+
+// parseWatchdog parses `ipmitool mc watchdog get` output such as
+//
+//	Watchdog Timer Use:     SMS/OS (0x44)
+//	Watchdog Timer Is:      Started/Running
+//	Watchdog Timer Actions: Hard Reset (0x01)
+//	Initial Countdown:      300 sec
+//	Present Countdown:      287 sec
+//
+// A watchdog that was never configured reports itself stopped with a zero
+// countdown.
+func parseWatchdog(output string) (*watchdogStatus, error) {
+	var (
+		status      = watchdogStatus{Action: "no_action"}
+		haveRunning bool
+	)
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Watchdog Timer Is":
+			status.Running = strings.Contains(strings.ToLower(value), "running") || strings.HasPrefix(strings.ToLower(value), "started")
+			haveRunning = true
+		case "Watchdog Timer Actions":
+			if action := watchdogAction(value); action != "" {
+				status.Action = action
+			}
+		case "Initial Countdown":
+			status.InitialCountdown = parseWatchdogCountdown(value)
+		case "Present Countdown":
+			status.PresentCountdown = parseWatchdogCountdown(value)
+		}
+	}
+
+	if !haveRunning {
+		return nil, fmt.Errorf("unexpected watchdog output")
+	}
+	return &status, nil
+}
+
+// watchdogAction turns an action such as "Hard Reset (0x01)" into
+// "hard_reset".
+func watchdogAction(value string) string {
+	if i := strings.Index(value, "("); i >= 0 {
+		value = value[:i]
+	}
+	return strings.Join(strings.Fields(strings.ToLower(value)), "_")
+}
+
+// parseWatchdogCountdown parses a countdown such as "300 sec", yielding 0 if
+// it is missing or malformed.
+func parseWatchdogCountdown(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return seconds
+}
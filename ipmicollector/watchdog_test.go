@@ -0,0 +1,56 @@
+package ipmicollector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWatchdog(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *watchdogStatus
+		wantErr bool
+	}{
+		{
+			name: "running",
+			output: `Watchdog Timer Use:     SMS/OS (0x44)
+Watchdog Timer Is:      Started/Running
+Watchdog Timer Actions: Hard Reset (0x01)
+Pre-timeout interval:   0 seconds
+Timer Expiration Flags: 0x10
+Initial Countdown:      300 sec
+Present Countdown:      287 sec
+`,
+			want: &watchdogStatus{Running: true, Action: "hard_reset", InitialCountdown: 300, PresentCountdown: 287},
+		},
+		{
+			name: "never configured",
+			output: `Watchdog Timer Use:     Reserved (0x00)
+Watchdog Timer Is:      Stopped
+Watchdog Timer Actions: No action (0x00)
+Initial Countdown:      0 sec
+Present Countdown:      0 sec
+`,
+			want: &watchdogStatus{Action: "no_action"},
+		},
+		{
+			name:   "malformed countdown",
+			output: "Watchdog Timer Is: Started/Running\nInitial Countdown: unknown\n",
+			want:   &watchdogStatus{Running: true, Action: "no_action"},
+		},
+		{
+			name:    "unexpected output",
+			output:  "Get Watchdog Timer command failed\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWatchdog(tt.output)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWatchdog() = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+const landingPageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>IPMI Prometheus Exporter</title></head>
+<body>
+<h1>IPMI Prometheus Exporter</h1>
+<p>Version: %s</p>
+<ul>
+<li><a href="%s">Metrics</a></li>
+<li><a href="/healthz">Health</a></li>
+</ul>
+<p>Scrape a single BMC on demand with <code>/ipmi?target=&lt;host&gt;</code>. POST to <code>/-/reload</code> or send SIGHUP to re-read the config file.</p>
+</body>
+</html>
+`
+
+// landingPageHandler serves a short index page at /, linking to
+// -web.telemetry-path, and 404s for every other path that falls through to
+// it.
+func landingPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprintf(w, landingPageTemplate, html.EscapeString(version), html.EscapeString(*telemetryPath))
+}
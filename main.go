@@ -1,276 +1,990 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
+	"os/signal"
 	"regexp"
-	"strconv"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
 )
 
+// shutdownGracePeriod bounds how long in-flight scrapes may take to finish
+// once a termination signal is received.
+const shutdownGracePeriod = 5 * time.Second
+
+// ipmiResponseTime is the part of -web.write-timeout an /ipmi scrape keeps
+// back from the collection for writing the metrics, at most half of it.
+const ipmiResponseTime = 5 * time.Second
+
+// Build information, set via -ldflags at release time.
 var (
-	version = "dev"
-	commit  = "none"
-	date    = "unknown"
+	version  = "dev"
+	revision = "none"
+	date     = "unknown"
 )
 
-type IPMIConfig struct {
-	Host     string
-	Username string
-	Password string
-	Port     int
+var (
+	configFile             = flag.String("config.file", "", "Path to a YAML file listing the IPMI targets to collect. Falls back to IPMI_* environment variables when unset.")
+	listenAddress          = flag.String("web.listen-address", ":8080", "Address to listen on for HTTP requests, either host:port or unix:/path/to/socket.")
+	collectInterval        = flag.Duration("collect.interval", 30*time.Second, "Interval between background sensor collections.")
+	ipmiPort               = flag.Int("ipmi.port", ipmicollector.DefaultPort, "Default BMC port for targets that do not set one.")
+	collectThresholds      = flag.Bool("collect.thresholds", false, "Collect sensor thresholds via an additional 'ipmitool sensor' call per cycle.")
+	ipmiTimeout            = flag.Duration("ipmi.timeout", ipmicollector.DefaultTimeout, "Maximum time a single ipmitool invocation may run before it is killed.")
+	collectDCMI            = flag.Bool("collect.dcmi", false, "Collect system power via an additional 'ipmitool dcmi power reading' call per cycle.")
+	logLevel               = flag.String("log.level", "info", "Only log messages with the given severity or above. One of: debug, info, warn, error.")
+	logFormat              = flag.String("log.format", "text", "Output format of log messages. One of: text, json.")
+	showVersion            = flag.Bool("version", false, "Print version information and exit.")
+	ipmiRetries            = flag.Int("ipmi.retries", ipmicollector.DefaultRetries, "Number of times to retry an ipmitool command after a transient session or connection error.")
+	collectSEL             = flag.Bool("collect.sel", false, "Collect System Event Log usage via an additional 'ipmitool sel info' call per cycle.")
+	maxConcurrency         = flag.Int("ipmi.max-concurrency", 4, "Maximum number of BMCs collected from concurrently.")
+	webConfigFile          = flag.String("web.config.file", "", "Path to a web configuration file enabling TLS and/or basic authentication. See https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md.")
+	collectEntityLabel     = flag.Bool("collect.entity-label", false, "Add the sensor entity (physical location) as an \"entity\" label. Increases cardinality.")
+	ipmiCipherSuite        = flag.String("ipmi.cipher-suite", "", "Cipher suite ID passed to ipmitool as -C for lanplus sessions, e.g. 3 (HMAC-SHA1, AES-CBC-128) or 17 (HMAC-SHA256, AES-CBC-128). Valid values are 0-17; unset uses the ipmitool default.")
+	ipmiInterface          = flag.String("ipmi.interface", ipmicollector.DefaultInterface, "Default ipmitool interface for targets that do not set one. One of: lan, lanplus, open. With open the local BMC is used and no host or credentials are passed.")
+	collectCacheTTL        = flag.Duration("collect.cache-ttl", 0, "Stop serving cached sensor readings older than this. Scrapes never trigger ipmitool, they read the last background poll. 0 serves cached readings regardless of age.")
+	ipmiCredentialsDir     = flag.String("ipmi.credentials-dir", "", "Directory holding per-host credential files named <host>.user and <host>.pass, used when a target has no username or password set.")
+	collectTemperatureUnit = flag.String("collect.temperature-unit", "celsius", "Unit for exported temperatures. One of: celsius, fahrenheit, kelvin.")
+	collectTimestamps      = flag.Bool("collect.timestamps", false, "Expose sensor samples with the time they were collected from the BMC instead of the scrape time.")
+	metricNamespace        = flag.String("metric.namespace", "", "Namespace prepended to all exported metric names, e.g. acme for acme_ipmi_up.")
+	checkConfig            = flag.Bool("check-config", false, "Validate the configuration and exit with a non-zero status if it is invalid.")
+	checkConfigCollect     = flag.Bool("check-config.collect", false, "With -check-config, also run one collection against each target and report whether it is reachable.")
+	collectNormalizeNames  = flag.Bool("collect.normalize-names", false, "Add a \"sensor\" label with the sensor name lowercased and non-alphanumeric characters replaced by underscores.")
+	collectInclude         = flag.String("collect.include", "", "Only collect sensors whose name matches this regular expression.")
+	collectExclude         = flag.String("collect.exclude", "", "Do not collect sensors whose name matches this regular expression. Takes precedence over -collect.include.")
+	ipmiOEM                = flag.String("ipmi.oem", "", "Default ipmitool OEM type passed as -o for targets that do not set one, e.g. supermicro or intelplus.")
+	collectChassis         = flag.Bool("collect.chassis", false, "Collect chassis power state and last power event via ipmitool chassis status.")
+	collectTypes           = flag.String("collect.types", "", "Comma-separated sensor types to export, out of voltage, temperature, fan, power, current, percent, airflow, humidity, energy, raw and discrete. Empty exports all.")
+	collectBMC             = flag.Bool("collect.bmc-info", false, "Collect BMC firmware and device information via ipmitool mc info and export it as ipmi_bmc_info.")
+	collectBMCInterval     = flag.Duration("collect.bmc-info-interval", time.Hour, "Interval between BMC information collections with -collect.bmc-info.")
+	collectOnError         = flag.String("collect.on-error", "keep", "What to serve for a host whose collection failed: keep the last readings, or clear them so only ipmi_up remains.")
+	collectJitter          = flag.Duration("collect.jitter", 0, "Maximum random delay before each background collection, including the first. Must be less than -collect.interval.")
+	ipmiSSHRelay           = flag.String("ipmi.ssh-relay", "", "Run ipmitool on this relay host over SSH instead of locally, given as user@host[:port].")
+	ipmiSSHKey             = flag.String("ipmi.ssh-key", "", "Private key file used to authenticate to -ipmi.ssh-relay.")
+	ipmiSSHKnownHosts      = flag.String("ipmi.ssh-known-hosts", "", "known_hosts file used to verify -ipmi.ssh-relay. Defaults to ~/.ssh/known_hosts.")
+	ipmiFromFile           = flag.String("ipmi.from-file", "", "Read sdr elist output from this file instead of running ipmitool, for testing and offline analysis. Without -config.file a target is served for every IPMI_HOST host, or one named localhost.")
+	ipmiDCMIPeriod         = flag.String("ipmi.dcmi-period", "", "Averaging period passed to ipmitool dcmi power reading with -collect.dcmi, e.g. 5_min. BMCs that reject it are queried without a period.")
+	sensorBackend          = flag.String("backend", "ipmitool", "Where sensors are read from. One of: ipmitool, redfish. With redfish sensors and their thresholds are read from the Redfish API of the BMC over HTTPS, and -collect.dcmi, -collect.sel, -collect.chassis, -collect.watchdog and -collect.bmc-info are not supported.")
+	redfishTLSCert         = flag.String("redfish.tls-cert", "", "Client certificate file presented to BMCs with -backend=redfish. Targets then need no username or password.")
+	redfishTLSKey          = flag.String("redfish.tls-key", "", "Private key file of -redfish.tls-cert.")
+	redfishTLSCA           = flag.String("redfish.tls-ca", "", "CA certificate file used to verify BMCs with -backend=redfish instead of the system roots.")
+	redfishTLSInsecure     = flag.Bool("redfish.tls-insecure-skip-verify", false, "Do not verify BMC certificates with -backend=redfish.")
+	ipmiInstanceName       = flag.String("ipmi.instance-name", "", "Logical name of the IPMI_HOST target when it lists a single host, such as web-prod-01, exported as the instance_name label. Defaults to the host. Config file targets set instance_name instead.")
+	ipmiBinaryPath         = flag.String("ipmi.binary-path", "", "Path to the ipmitool binary. Defaults to ipmitool looked up in $PATH. Ignored with -ipmi.ssh-relay, which runs ipmitool from the relay's $PATH.")
+	collectVoltageRails    = flag.String("collect.voltage-rails", "", "Comma-separated nominal voltages, such as 3.3,5,12, to classify voltage sensors into. Adds a rail label to ipmi_voltage_volts with the one in the sensor name, such as 12v for P12V, or else the one nearest the first reading, or other if that is more than 15% off all of them. Empty omits the label.")
+	ipmiSessionTimeout     = flag.Duration("ipmi.session-timeout", 0, "Time ipmitool waits for a BMC response before retransmitting, passed as -N in whole seconds. Helps on lossy networks; unlike -ipmi.timeout it applies to every packet. 0 leaves the ipmitool default.")
+	ipmiLANRetries         = flag.Int("ipmi.lan-retries", 0, "How often ipmitool retransmits an unanswered packet, passed as -R. Independent of -ipmi.retries, which reruns whole commands. 0 leaves the ipmitool default.")
+	collectMaxSeries       = flag.Int("collect.max-series", 0, "Maximum number of sensor series exported per host and scrape. Excess series are dropped and counted in ipmi_series_dropped_total. 0 disables the limit.")
+	otlpEndpoint           = flag.String("output.otlp-endpoint", "", "OTLP/HTTP endpoint, such as http://otel-collector:4318, to push the IPMI metrics of the background collection targets to every -collect.interval, as served on /metrics. Empty disables OTLP export.")
+	collectSDRQuery        = flag.String("collect.sdr-query", "elist", "How sensors are listed: elist reads all of them with one call in the -ipmi.sdr-format format, type reads only the -collect.types types, one sdr type call each. type requires -collect.types out of voltage, temperature, fan and current.")
+	collectResolveHost     = flag.Bool("collect.resolve-host", false, "Export the name a reverse DNS lookup of a host configured by IP address returns as the host label, falling back to the address if the lookup fails. ipmitool still connects to the address.")
+	collectLastChange      = flag.Bool("collect.last-change", false, "Export ipmi_sensor_last_change_timestamp_seconds, when the reading of each sensor last changed, to detect stuck sensors. Keeps the last reading of every sensor in memory.")
+	telemetryPath          = flag.String("web.telemetry-path", "/metrics", "Path under which to expose the metrics of the background collection targets.")
+	ipmiMaxOutputBytes     = flag.Int64("ipmi.max-output-bytes", ipmicollector.DefaultMaxOutputBytes, "Maximum size of the output of a single ipmitool command. A command printing more is killed and fails the collection.")
+	collectEnergyCounters  = flag.Bool("collect.energy-counters", false, "Export energy sensors as the counter ipmi_energy_joules_total instead of the gauge ipmi_energy_joules, continuing across resets of the total by the BMC. With -collect.dcmi, also export ipmi_dcmi_energy_joules_total integrated from the DCMI power readings.")
+	ipmiSDRFormat          = flag.String("ipmi.sdr-format", "elist", "Sensor listing to read and parse: elist for sdr elist full, list for sdr list full, which has no sensor IDs or entities, or v for -v sdr list full. Also applies to -ipmi.from-file.")
+	collectSensorInfo      = flag.Bool("collect.sensor-info", false, "Export ipmi_sensor_info with the status, unit and entity of every sensor as labels. Adds a series per sensor and a new one whenever a status changes.")
+	collectWatchdog        = flag.Bool("collect.watchdog", false, "Collect whether the BMC watchdog timer is running and its countdown via ipmitool mc watchdog get.")
+	oneshot                = flag.Bool("oneshot", false, "Collect once from every configured target, write the metrics to stdout in the Prometheus text format and exit, with a non-zero status if a target failed.")
+	webReadTimeout         = flag.Duration("web.read-timeout", 30*time.Second, "Maximum time to read an HTTP request, including its body.")
+	webWriteTimeout        = flag.Duration("web.write-timeout", 2*time.Minute, "Maximum time from the end of reading an HTTP request to the end of writing the response. /ipmi collections still running shortly before it expires are cut short and served as ipmi_up 0; to avoid that, make it longer than -ipmi.timeout for every attempt of every enabled command, plus the retry backoff.")
+	ipmiLocal              = flag.Bool("ipmi.local", false, "Collect from the BMC of the machine the exporter runs on through the open interface, without a host or credentials. IPMI_HOST optionally sets the host label, which defaults to localhost.")
+	breakerFailures        = flag.Int("collect.circuit-breaker-failures", 0, "After this many consecutive failed collections from a target, stop collecting from it for -collect.interval, doubling the wait after every further failure up to -collect.circuit-breaker-max-backoff. 0 disables the circuit breaker.")
+	breakerMaxBackoff      = flag.Duration("collect.circuit-breaker-max-backoff", 10*time.Minute, "Longest wait between collections from a failing target with -collect.circuit-breaker-failures.")
+	collectBreaches        = flag.Bool("collect.threshold-breaches", false, "Export ipmi_sensor_threshold_breaches_total, how many times each sensor went from ok to a non-critical, critical or non-recoverable status. Keeps the last status of every sensor in memory.")
+	adhocEnvCredentials    = flag.Bool("ipmi.ad-hoc-env-credentials", false, "Let /ipmi scrapes of targets that are not configured fall back to IPMI_USERNAME and IPMI_PASSWORD when they pass no credentials. The exporter then authenticates with them to any host a client names.")
+)
+
+// getIPMIConfigs reads the background collection targets from the
+// environment. IPMI_HOST may list several comma-separated hosts, which share
+// IPMI_USERNAME and IPMI_PASSWORD unless overridden per host as described at
+// hostEnvName. The result is empty when IPMI_HOST is unset, in which case the
+// exporter only serves on-demand scrapes via /ipmi.
+func getIPMIConfigs() ([]ipmicollector.IPMIConfig, error) {
+	hosts, err := envHosts()
+	if err != nil || hosts == nil {
+		return nil, err
+	}
+	sharedUsername := os.Getenv("IPMI_USERNAME")
+	sharedPassword, err := getIPMIPassword()
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]ipmicollector.IPMIConfig, 0, len(hosts))
+	for _, host := range hosts {
+		username, ok := os.LookupEnv(hostEnvName("IPMI_USER", host))
+		if !ok {
+			username = sharedUsername
+		}
+		password, ok := os.LookupEnv(hostEnvName("IPMI_PASSWORD", host))
+		if !ok {
+			password = sharedPassword
+		}
+		config := ipmicollector.IPMIConfig{
+			Host:         host,
+			Username:     username,
+			Password:     password,
+			Port:         *ipmiPort,
+			InstanceName: *ipmiInstanceName,
+		}
+		if err := resolveCredentials(&config, *ipmiCredentialsDir); err != nil {
+			return nil, err
+		}
+		if needsCredentials(config) && (config.Username == "" || config.Password == "") {
+			return nil, fmt.Errorf("no credentials for %s: set IPMI_USERNAME and IPMI_PASSWORD (or IPMI_PASSWORD_FILE, %s and %s, or -ipmi.credentials-dir)", host, hostEnvName("IPMI_USER", host), hostEnvName("IPMI_PASSWORD", host))
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
 }
 
-type SensorData struct {
-	Name   string
-	ID     string
-	Status string
-	Entity string
-	Value  float64
-	Unit   string
-	Type   string
+// hostEnvSanitizer matches the characters of a host not allowed in an
+// environment variable name.
+var hostEnvSanitizer = regexp.MustCompile(`[^A-Z0-9]`)
+
+// hostEnvName returns the name of the per-host variant of the environment
+// variable prefix: the host uppercased, with every character other than a
+// letter or digit replaced by an underscore, appended after an underscore.
+// For example the password of bmc-1.example.com is read from
+// IPMI_PASSWORD_BMC_1_EXAMPLE_COM.
+func hostEnvName(prefix, host string) string {
+	return prefix + "_" + hostEnvSanitizer.ReplaceAllString(strings.ToUpper(host), "_")
 }
 
-var (
-	voltageGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "ipmi_voltage_volts",
-			Help: "IPMI voltage sensor readings in volts",
-		},
-		[]string{"sensor_name", "sensor_id", "host"},
-	)
+// envHosts returns the normalized hosts listed in IPMI_HOST, or nil if it is
+// unset. Empty and duplicate entries and hosts with a port are rejected, as
+// is -ipmi.instance-name with more than one host.
+func envHosts() ([]string, error) {
+	value := os.Getenv("IPMI_HOST")
+	if value == "" {
+		return nil, nil
+	}
+	var hosts []string
+	for i, entry := range strings.Split(value, ",") {
+		host := ipmicollector.NormalizeHost(entry)
+		if host == "" {
+			return nil, fmt.Errorf("IPMI_HOST entry %d is empty", i+1)
+		}
+		if err := ipmicollector.ValidateHost(host); err != nil {
+			return nil, fmt.Errorf("IPMI_HOST entry %d: %v", i+1, err)
+		}
+		if slices.Contains(hosts, host) {
+			return nil, fmt.Errorf("IPMI_HOST lists %s more than once", host)
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) > 1 && *ipmiInstanceName != "" {
+		return nil, fmt.Errorf("-ipmi.instance-name requires a single IPMI_HOST")
+	}
+	return hosts, nil
+}
 
-	temperatureGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "ipmi_temperature_celsius",
-			Help: "IPMI temperature sensor readings in celsius",
-		},
-		[]string{"sensor_name", "sensor_id", "host"},
-	)
+// loadTargets returns the targets to collect from in the background: those
+// of -config.file if set, otherwise those described by the environment, if
+// any. The parsed config file is returned for use with /ipmi.
+func loadTargets() (*Config, []ipmicollector.IPMIConfig, error) {
+	if *configFile != "" {
+		fileConfig, err := LoadConfig(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileConfig, fileConfig.Targets, nil
+	}
 
-	fanGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "ipmi_fan_speed_rpm",
-			Help: "IPMI fan speed sensor readings in RPM",
-		},
-		[]string{"sensor_name", "sensor_id", "host"},
-	)
+	if *ipmiFromFile != "" || *ipmiLocal {
+		// No BMC is contacted over the network, so no credentials are
+		// needed and IPMI_HOST only names the targets.
+		hosts, err := envHosts()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid environment configuration: %v", err)
+		}
+		if hosts == nil {
+			hosts = []string{"localhost"}
+		}
+		targets := make([]ipmicollector.IPMIConfig, 0, len(hosts))
+		for _, host := range hosts {
+			targets = append(targets, ipmicollector.IPMIConfig{Host: host, Port: *ipmiPort, Interface: "open", InstanceName: *ipmiInstanceName})
+		}
+		return nil, targets, nil
+	}
 
-	powerGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "ipmi_power_watts",
-			Help: "IPMI power sensor readings in watts",
-		},
-		[]string{"sensor_name", "sensor_id", "host"},
-	)
+	configs, err := getIPMIConfigs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid environment configuration: %v", err)
+	}
+	return nil, configs, nil
+}
 
-	currentGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "ipmi_current_amperes",
-			Help: "IPMI current sensor readings in amperes",
-		},
-		[]string{"sensor_name", "sensor_id", "host"},
-	)
-)
+// getIPMIPassword returns the password from the file named by
+// IPMI_PASSWORD_FILE when set, falling back to IPMI_PASSWORD.
+func getIPMIPassword() (string, error) {
+	path := os.Getenv("IPMI_PASSWORD_FILE")
+	if path == "" {
+		return os.Getenv("IPMI_PASSWORD"), nil
+	}
 
-func init() {
-	prometheus.MustRegister(voltageGauge)
-	prometheus.MustRegister(temperatureGauge)
-	prometheus.MustRegister(fanGauge)
-	prometheus.MustRegister(powerGauge)
-	prometheus.MustRegister(currentGauge)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IPMI_PASSWORD_FILE: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
 
-func getIPMIConfig() IPMIConfig {
-	host := os.Getenv("IPMI_HOST")
-	username := os.Getenv("IPMI_USERNAME")
-	password := os.Getenv("IPMI_PASSWORD")
-	if host == "" || username == "" || password == "" {
-		log.Fatal("IPMI_HOST, IPMI_USERNAME, and IPMI_PASSWORD environment variables must be set")
-	}
-	return IPMIConfig{
-		Host:     host,
-		Username: username,
-		Password: password,
-		Port:     623,
+// reservedPaths are the paths of the endpoints other than the metrics
+// endpoint, which -web.telemetry-path must not shadow.
+var reservedPaths = []string{"/ipmi", "/-/reload", "/healthz", "/readyz"}
+
+// metricNamespacePattern matches namespaces that keep metric names valid.
+var metricNamespacePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ipmiHandler serves metrics for a single BMC given by the "target" query
+// parameter, running ipmitool on demand for every request. Targets listed in
+// the config file or IPMI_HOST use their configured settings; otherwise
+// credentials are taken from the "username" and "password" parameters. The
+// counters of every target accumulate in counters across its scrapes.
+func ipmiHandler(targets *targetSet, opts []ipmicollector.Option, counters *adhocMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		target := query.Get("target")
+		if target == "" {
+			http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+			return
+		}
+
+		config, ok := targets.target(target)
+		if !ok {
+			var err error
+			if config, err = queryIPMIConfig(query); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The counters of the target are served with its metrics rather
+		// than on /metrics, where arbitrary targets would pile up series.
+		metrics := counters.get(config, time.Now())
+		collector := newCollector(config, append(slices.Clone(opts), ipmicollector.WithMetrics(metrics)))
+		// The collection is cut short before the server drops the response
+		// at its write timeout, so a slow BMC is served as ipmi_up 0.
+		ctx, cancel := context.WithTimeout(r.Context(), ipmiCollectionTimeout(*webWriteTimeout))
+		defer cancel()
+		if *collectBMC {
+			refreshBMCInfo(ctx, []*ipmicollector.Collector{collector})
+		}
+		// A failed collection is served as ipmi_up 0 rather than an HTTP
+		// error, so Prometheus records it as a metric like /metrics does.
+		// Refresh has logged the error already.
+		err := collector.Refresh(ctx)
+		if err != nil && r.Context().Err() != nil {
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		if running, ok := targets.collector(config); ok && errors.Is(err, ipmicollector.ErrCollectionInProgress) {
+			// Rather than open a second session, a configured target is
+			// served the readings of its background collection, whose
+			// counters are on /metrics. Any other target is served ipmi_up 0.
+			registry.MustRegister(running)
+		} else {
+			registry.MustRegister(collector, metrics)
+		}
+
+		metricsHandler(registry).ServeHTTP(w, r)
 	}
 }
 
-func executeIPMICommand(config IPMIConfig) (string, error) {
-	cmd := exec.Command("ipmitool",
-		"-I", "lanplus",
-		"-H", config.Host,
-		"-U", config.Username,
-		"-P", config.Password,
-		"sdr", "elist", "full")
+// ipmiCollectionTimeout returns how long an /ipmi collection may run for the
+// response to be written within writeTimeout.
+func ipmiCollectionTimeout(writeTimeout time.Duration) time.Duration {
+	return writeTimeout - min(ipmiResponseTime, writeTimeout/2)
+}
 
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to execute ipmitool command: %v", err)
+// queryIPMIConfig builds the config for an ad-hoc /ipmi target. Credentials
+// come from the query string, then -ipmi.credentials-dir, and with
+// -ipmi.ad-hoc-env-credentials then the environment. Without that flag the
+// shared credentials are never sent to a host a client picked.
+func queryIPMIConfig(query url.Values) (ipmicollector.IPMIConfig, error) {
+	config := ipmicollector.IPMIConfig{
+		Host:     ipmicollector.NormalizeHost(query.Get("target")),
+		Username: query.Get("username"),
+		Password: query.Get("password"),
+		Port:     *ipmiPort,
+	}
+	if err := ipmicollector.ValidateHost(config.Host); err != nil {
+		return ipmicollector.IPMIConfig{}, err
+	}
+
+	if *ipmiCredentialsDir != "" {
+		if err := resolveCredentials(&config, *ipmiCredentialsDir); err != nil {
+			return ipmicollector.IPMIConfig{}, err
+		}
+	}
+	if *adhocEnvCredentials {
+		if config.Username == "" {
+			config.Username = os.Getenv("IPMI_USERNAME")
+		}
+		if config.Password == "" {
+			var err error
+			if config.Password, err = getIPMIPassword(); err != nil {
+				return ipmicollector.IPMIConfig{}, err
+			}
+		}
+	}
+	if needsCredentials(config) && (config.Username == "" || config.Password == "") {
+		return ipmicollector.IPMIConfig{}, fmt.Errorf("'username' and 'password' parameters must be specified")
 	}
 
-	return string(output), nil
+	return config, nil
 }
 
-func parseSensorData(sdrData string) []SensorData {
-	var sensors []SensorData
-	lines := strings.Split(sdrData, "\n")
+// collectorOptionsFromFlags returns the collector options selected on the
+// command line, running ipmitool through runner and counting into metrics.
+// A nil backend reads sensors with ipmitool.
+func collectorOptionsFromFlags(runner ipmicollector.CommandRunner, backend ipmicollector.SensorBackend, metrics *ipmicollector.Metrics) []ipmicollector.Option {
+	// The filter and types are validated at startup.
+	filter, _ := ipmicollector.NewSensorFilter(*collectInclude, *collectExclude)
+	types, _ := ipmicollector.ParseSensorTypes(*collectTypes)
+	rails, _ := ipmicollector.ParseVoltageRails(*collectVoltageRails)
+
+	opts := []ipmicollector.Option{
+		ipmicollector.WithNamespace(*metricNamespace),
+		ipmicollector.WithTimeout(*ipmiTimeout),
+		ipmicollector.WithRetries(*ipmiRetries),
+		ipmicollector.WithSessionTimeout(*ipmiSessionTimeout),
+		ipmicollector.WithLANRetries(*ipmiLANRetries),
+		ipmicollector.WithCacheTTL(*collectCacheTTL),
+		ipmicollector.WithTemperatureUnit(*collectTemperatureUnit),
+		ipmicollector.WithSensorFilter(filter),
+		ipmicollector.WithSensorTypes(types...),
+		ipmicollector.WithVoltageRails(rails...),
+		ipmicollector.WithMaxSeries(*collectMaxSeries),
+		ipmicollector.WithRunner(runner),
+		ipmicollector.WithMetrics(metrics),
+	}
+	if backend != nil {
+		opts = append(opts, ipmicollector.WithBackend(backend))
+	}
+	if *collectEntityLabel {
+		opts = append(opts, ipmicollector.WithEntityLabel())
+	}
+	if *collectNormalizeNames {
+		opts = append(opts, ipmicollector.WithNormalizedNames())
+	}
+	if *collectTimestamps {
+		opts = append(opts, ipmicollector.WithTimestamps())
+	}
+	if *collectLastChange {
+		opts = append(opts, ipmicollector.WithLastChange())
+	}
+	if *breakerFailures > 0 {
+		opts = append(opts, ipmicollector.WithCircuitBreaker(*breakerFailures, *collectInterval, *breakerMaxBackoff))
+	}
+	if *collectSensorInfo {
+		opts = append(opts, ipmicollector.WithSensorInfo())
+	}
+	if *collectBreaches {
+		opts = append(opts, ipmicollector.WithThresholdBreaches())
+	}
+	if *collectEnergyCounters {
+		opts = append(opts, ipmicollector.WithEnergyCounters())
+	}
+	if *collectOnError == "clear" {
+		opts = append(opts, ipmicollector.WithClearOnError())
+	}
+	if *ipmiSDRFormat != "elist" {
+		opts = append(opts, ipmicollector.WithSDRFormat(*ipmiSDRFormat))
+	}
+	if *collectSDRQuery == "type" {
+		opts = append(opts, ipmicollector.WithSDRTypeQueries())
+	}
+	if *collectThresholds {
+		opts = append(opts, ipmicollector.WithThresholds())
+	}
+	if *collectDCMI {
+		opts = append(opts, ipmicollector.WithDCMI(*ipmiDCMIPeriod))
+	}
+	if *collectSEL {
+		opts = append(opts, ipmicollector.WithSEL())
+	}
+	if *collectChassis {
+		opts = append(opts, ipmicollector.WithChassis())
+	}
+	if *collectWatchdog {
+		opts = append(opts, ipmicollector.WithWatchdog())
+	}
+	return opts
+}
 
-	sensorRegex := regexp.MustCompile(`^([^|]+)\s*\|\s*([^|]+)\s*\|\s*(\w+)\s*\|\s*([^|]+)\s*\|\s*(.+)$`)
+// newCollector returns a collector for config, with the -ipmi.* defaults
+// applied to the settings it leaves unset.
+func newCollector(config ipmicollector.IPMIConfig, opts []ipmicollector.Option) *ipmicollector.Collector {
+	config.Interface = effectiveInterface(config)
+	if config.Port == 0 {
+		config.Port = *ipmiPort
+	}
+	if config.CipherSuite == "" {
+		config.CipherSuite = *ipmiCipherSuite
+	}
+	if config.OEM == "" {
+		config.OEM = *ipmiOEM
+	}
+	if *collectResolveHost {
+		opts = append(slices.Clone(opts), ipmicollector.WithHostLabel(hostNames.lookup(config.Host)))
+	}
+	return ipmicollector.NewCollector(config, opts...)
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+// newCollectorEnabledGauge returns a gauge that is 1 for every collector
+// enabled on the command line and 0 for the others, so a missing metric can
+// be told apart from a disabled collector.
+func newCollectorEnabledGauge(namespace string) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ipmi_collector_enabled",
+		Help:      "Whether a collector is enabled: 1 if it is and 0 otherwise",
+	}, []string{"collector"})
+	for collector, enabled := range map[string]bool{
+		"sensors":    true,
+		"thresholds": *collectThresholds,
+		"dcmi":       *collectDCMI,
+		"sel":        *collectSEL,
+		"chassis":    *collectChassis,
+		"watchdog":   *collectWatchdog,
+		"bmc_info":   *collectBMC,
+	} {
+		value := 0.0
+		if enabled {
+			value = 1
 		}
+		gauge.WithLabelValues(collector).Set(value)
+	}
+	return gauge
+}
 
-		matches := sensorRegex.FindStringSubmatch(line)
-		if len(matches) != 6 {
-			continue
-		}
+// newBuildInfoGauge returns a gauge that is always 1 and carries the build
+// information as labels.
+func newBuildInfoGauge(namespace string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ipmi_exporter_build_info",
+		Help:      "A metric with a constant '1' value labeled by version, revision and goversion from which the exporter was built",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	gauge.Set(1)
+	return gauge
+}
 
-		name := strings.TrimSpace(matches[1])
-		id := strings.TrimSpace(matches[2])
-		status := strings.TrimSpace(matches[3])
-		entity := strings.TrimSpace(matches[4])
-		valueStr := strings.TrimSpace(matches[5])
+// newCollectionIntervalGauge returns a gauge set to the -collect.interval
+// the background collection runs at, to spot instances polling shared BMCs
+// too often.
+func newCollectionIntervalGauge(namespace string, interval time.Duration) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ipmi_collection_interval_seconds",
+		Help:      "Configured interval between background collections from each target",
+	})
+	gauge.Set(interval.Seconds())
+	return gauge
+}
 
-		if status != "ok" {
-			continue
-		}
+// redfishTLSConfig builds the TLS config for connections to Redfish BMCs
+// from the -redfish.tls-* flags.
+func redfishTLSConfig() (*tls.Config, error) {
+	if (*redfishTLSCert == "") != (*redfishTLSKey == "") {
+		return nil, fmt.Errorf("-redfish.tls-cert and -redfish.tls-key must be set together")
+	}
 
-		if strings.Contains(valueStr, "No Reading") {
-			continue
+	// Skipping verification is opt-in, for BMCs with self-signed certificates.
+	config := &tls.Config{InsecureSkipVerify: *redfishTLSInsecure} //nolint:gosec
+	if *redfishTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(*redfishTLSCert, *redfishTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
 		}
-
-		value, unit, sensorType := parseValue(valueStr)
-		if value == 0 && unit == "" {
-			continue
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if *redfishTLSCA != "" {
+		pem, err := os.ReadFile(*redfishTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", *redfishTLSCA)
 		}
+	}
+	return config, nil
+}
+
+// newLogger builds the process logger from the -log.level and -log.format
+// flag values.
+func newLogger(level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log.level %q: must be one of debug, info, warn, error", level)
+	}
 
-		sensors = append(sensors, SensorData{
-			Name:   name,
-			ID:     id,
-			Status: status,
-			Entity: entity,
-			Value:  value,
-			Unit:   unit,
-			Type:   sensorType,
-		})
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
 	}
+	return nil, fmt.Errorf("invalid -log.format %q: must be text or json", format)
+}
 
-	return sensors
+// newRegistry returns the registry served on /metrics, with the Go runtime
+// and process collectors registered. It is a dedicated registry rather than
+// the global default, so the runtime collectors are registered explicitly
+// and nothing else that links against client_golang can add metrics behind
+// our back.
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
 }
 
-func parseValue(valueStr string) (float64, string, string) {
-	valueStr = strings.TrimSpace(valueStr)
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	if strings.Contains(valueStr, "Volts") {
-		parts := strings.Fields(valueStr)
-		if len(parts) >= 1 {
-			if val, err := strconv.ParseFloat(parts[0], 64); err == nil {
-				return val, "volts", "voltage"
+// collectAll collects from every collector concurrently, with at most
+// maxConcurrency collections in flight. A slow or failing host only occupies
+// its own slot and does not hold up the others. The cycle is reported to
+// health as one result, failed only if every collection that ran failed, so
+// readiness doesn't depend on the order the hosts finish in.
+func collectAll(ctx context.Context, collectors []*ipmicollector.Collector, maxConcurrency int, health *readiness) {
+	sem := make(chan struct{}, maxConcurrency)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		ran       bool
+		succeeded bool
+		errs      []error
+	)
+
+	for _, collector := range collectors {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(collector *ipmicollector.Collector) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := collector.Refresh(ctx)
+			if errors.Is(err, ipmicollector.ErrCollectionInProgress) || errors.Is(err, ipmicollector.ErrCircuitOpen) {
+				return
 			}
-		}
+			mu.Lock()
+			defer mu.Unlock()
+			ran = true
+			if err == nil {
+				succeeded = true
+			} else {
+				errs = append(errs, err)
+			}
+		}(collector)
 	}
 
-	if strings.Contains(valueStr, "degrees C") {
-		parts := strings.Fields(valueStr)
-		if len(parts) >= 1 {
-			if val, err := strconv.ParseFloat(parts[0], 64); err == nil {
-				return val, "celsius", "temperature"
-			}
-		}
+	wg.Wait()
+	if !ran {
+		return
+	}
+	if succeeded {
+		health.record(nil)
+	} else {
+		health.record(errors.Join(errs...))
+	}
+}
+
+// startMetricsCollection collects once and then keeps collecting every
+// interval until ctx is cancelled, from the collectors returned by collectors
+// at the start of each cycle. Results are reported to health for
+// /readyz. Without jitter the first collection runs synchronously; with it,
+// the first and every later collection are delayed by a random duration of
+// up to jitter, so exporters started together don't poll their BMCs at the
+// same instant.
+func startMetricsCollection(ctx context.Context, collectors func() []*ipmicollector.Collector, interval, jitter time.Duration, health *readiness) {
+	if jitter == 0 {
+		collectAll(ctx, collectors(), *maxConcurrency, health)
 	}
 
-	if strings.Contains(valueStr, "RPM") {
-		parts := strings.Fields(valueStr)
-		if len(parts) >= 1 {
-			if val, err := strconv.ParseFloat(parts[0], 64); err == nil {
-				return val, "rpm", "fan"
+	go func() {
+		if jitter > 0 {
+			if !sleepJitter(ctx, jitter) {
+				return
 			}
+			collectAll(ctx, collectors(), *maxConcurrency, health)
 		}
-	}
 
-	if strings.Contains(valueStr, "Watts") {
-		parts := strings.Fields(valueStr)
-		if len(parts) >= 1 {
-			if val, err := strconv.ParseFloat(parts[0], 64); err == nil {
-				return val, "watts", "power"
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !sleepJitter(ctx, jitter) {
+					return
+				}
+				collectAll(ctx, collectors(), *maxConcurrency, health)
 			}
 		}
+	}()
+}
+
+// refreshBMCInfo collects the BMC information for every collector. Failures
+// are logged and leave the previous information in place, since it is only
+// inventory data.
+func refreshBMCInfo(ctx context.Context, collectors []*ipmicollector.Collector) {
+	for _, collector := range collectors {
+		if err := collector.RefreshBMCInfo(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to collect BMC info", "host", collector.Config().Host, "err", err)
+		}
 	}
+}
+
+// startBMCInfoCollection collects the BMC information once and then every
+// interval until ctx is cancelled. It changes rarely, so it runs on its own
+// ticker, slower than the one for sensors.
+func startBMCInfoCollection(ctx context.Context, collectors func() []*ipmicollector.Collector, interval time.Duration) {
+	go func() {
+		refreshBMCInfo(ctx, collectors())
 
-	if strings.Contains(valueStr, "Amps") {
-		parts := strings.Fields(valueStr)
-		if len(parts) >= 1 {
-			if val, err := strconv.ParseFloat(parts[0], 64); err == nil {
-				return val, "amperes", "current"
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshBMCInfo(ctx, collectors())
 			}
 		}
+	}()
+}
+
+// sleepJitter waits for a random duration in [0, jitter). It returns false
+// if ctx was cancelled first.
+func sleepJitter(ctx context.Context, jitter time.Duration) bool {
+	if jitter <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(jitterDelay(jitter))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
+}
 
-	return 0, "", ""
+// jitterDelay returns a random duration in [0, jitter), or 0 without jitter.
+func jitterDelay(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return rand.N(jitter)
 }
 
-func updateMetrics(sensors []SensorData, host string) {
-	for _, sensor := range sensors {
-		switch sensor.Type {
-		case "voltage":
-			voltageGauge.WithLabelValues(sensor.Name, sensor.ID, host).Set(sensor.Value)
-		case "temperature":
-			temperatureGauge.WithLabelValues(sensor.Name, sensor.ID, host).Set(sensor.Value)
-		case "fan":
-			fanGauge.WithLabelValues(sensor.Name, sensor.ID, host).Set(sensor.Value)
-		case "power":
-			powerGauge.WithLabelValues(sensor.Name, sensor.ID, host).Set(sensor.Value)
-		case "current":
-			currentGauge.WithLabelValues(sensor.Name, sensor.ID, host).Set(sensor.Value)
+// validateFlags checks the command-line flags for values and combinations
+// the exporter can't run with, along with the IPMI_HOST list they apply to.
+// Flags that need more than their value to check, such as the ipmitool
+// binary, are checked when they are used.
+func validateFlags() error {
+	if *collectInterval < time.Second {
+		return fmt.Errorf("-collect.interval must be at least 1s, got %v", *collectInterval)
+	}
+	if *collectJitter < 0 || *collectJitter >= *collectInterval {
+		return fmt.Errorf("-collect.jitter must be at least 0 and less than -collect.interval, got %v with an interval of %v", *collectJitter, *collectInterval)
+	}
+	if *ipmiInterface == "" {
+		return fmt.Errorf("-ipmi.interface must not be empty")
+	}
+	if err := ipmicollector.ValidateInterface(*ipmiInterface); err != nil {
+		return fmt.Errorf("invalid -ipmi.interface: %v", err)
+	}
+	if err := ipmicollector.ValidateCipherSuite(*ipmiCipherSuite); err != nil {
+		return fmt.Errorf("invalid -ipmi.cipher-suite: %v", err)
+	}
+	if err := ipmicollector.ValidateDCMIPeriod(*ipmiDCMIPeriod); err != nil {
+		return fmt.Errorf("invalid -ipmi.dcmi-period: %v", err)
+	}
+	if err := ipmicollector.ValidateOEM(*ipmiOEM); err != nil {
+		return fmt.Errorf("invalid -ipmi.oem: %v", err)
+	}
+	if err := ipmicollector.ValidateSDRFormat(*ipmiSDRFormat); err != nil {
+		return fmt.Errorf("invalid -ipmi.sdr-format: %v", err)
+	}
+	if err := ipmicollector.ValidateTemperatureUnit(*collectTemperatureUnit); err != nil {
+		return fmt.Errorf("invalid -collect.temperature-unit: %v", err)
+	}
+	if *metricNamespace != "" && !metricNamespacePattern.MatchString(*metricNamespace) {
+		return fmt.Errorf("invalid -metric.namespace %q", *metricNamespace)
+	}
+	if _, err := ipmicollector.NewSensorFilter(*collectInclude, *collectExclude); err != nil {
+		return fmt.Errorf("invalid sensor filter: %v", err)
+	}
+	types, err := ipmicollector.ParseSensorTypes(*collectTypes)
+	if err != nil {
+		return fmt.Errorf("invalid -collect.types: %v", err)
+	}
+	switch *collectSDRQuery {
+	case "elist":
+	case "type":
+		if err := ipmicollector.ValidateSDRTypes(types); err != nil {
+			return fmt.Errorf("-collect.sdr-query=type requires -collect.types with types ipmitool can query: %v", err)
+		}
+		if *ipmiFromFile != "" {
+			return fmt.Errorf("-collect.sdr-query=type can't be used with -ipmi.from-file")
+		}
+		if *ipmiSDRFormat != "elist" {
+			return fmt.Errorf("-collect.sdr-query=type can't be used with -ipmi.sdr-format %s", *ipmiSDRFormat)
+		}
+	default:
+		return fmt.Errorf("-collect.sdr-query must be elist or type, got %q", *collectSDRQuery)
+	}
+	if _, err := ipmicollector.ParseVoltageRails(*collectVoltageRails); err != nil {
+		return fmt.Errorf("invalid -collect.voltage-rails: %v", err)
+	}
+	if *collectBMCInterval < time.Second {
+		return fmt.Errorf("-collect.bmc-info-interval must be at least 1s, got %v", *collectBMCInterval)
+	}
+	if *collectOnError != "keep" && *collectOnError != "clear" {
+		return fmt.Errorf("-collect.on-error must be keep or clear, got %q", *collectOnError)
+	}
+	if *ipmiLocal && (*configFile != "" || *sensorBackend != "ipmitool") {
+		return fmt.Errorf("-ipmi.local can't be used with -config.file or -backend=redfish")
+	}
+	if *ipmiFromFile != "" && *ipmiSSHRelay != "" {
+		return fmt.Errorf("-ipmi.from-file and -ipmi.ssh-relay are mutually exclusive")
+	}
+	if *configFile == "" {
+		if _, err := envHosts(); err != nil {
+			return err
+		}
+	}
+	switch *sensorBackend {
+	case "ipmitool":
+	case "redfish":
+		if *collectDCMI || *collectSEL || *collectChassis || *collectWatchdog || *collectBMC || *ipmiFromFile != "" || *ipmiSSHRelay != "" {
+			return fmt.Errorf("-backend=redfish does not support -collect.dcmi, -collect.sel, -collect.chassis, -collect.watchdog, -collect.bmc-info, -ipmi.from-file or -ipmi.ssh-relay")
+		}
+	default:
+		return fmt.Errorf("-backend must be ipmitool or redfish, got %q", *sensorBackend)
+	}
+	if !strings.HasPrefix(*telemetryPath, "/") || *telemetryPath == "/" || slices.Contains(reservedPaths, *telemetryPath) {
+		return fmt.Errorf("-web.telemetry-path must be an absolute path other than those of the exporter's other endpoints, got %q", *telemetryPath)
+	}
+	if *otlpEndpoint != "" {
+		if u, err := url.Parse(*otlpEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("-output.otlp-endpoint must be an http or https URL, got %q", *otlpEndpoint)
 		}
 	}
+	if *collectMaxSeries < 0 {
+		return fmt.Errorf("-collect.max-series must not be negative, got %d", *collectMaxSeries)
+	}
+	if *ipmiMaxOutputBytes < 1 {
+		return fmt.Errorf("-ipmi.max-output-bytes must be at least 1, got %d", *ipmiMaxOutputBytes)
+	}
+	if *ipmiSessionTimeout < 0 {
+		return fmt.Errorf("-ipmi.session-timeout must not be negative, got %v", *ipmiSessionTimeout)
+	}
+	if *ipmiLANRetries < 0 {
+		return fmt.Errorf("-ipmi.lan-retries must not be negative, got %d", *ipmiLANRetries)
+	}
+	if *breakerFailures < 0 {
+		return fmt.Errorf("-collect.circuit-breaker-failures must not be negative, got %d", *breakerFailures)
+	}
+	if *breakerFailures > 0 && *breakerMaxBackoff < *collectInterval {
+		return fmt.Errorf("-collect.circuit-breaker-max-backoff must be at least -collect.interval, got %v with an interval of %v", *breakerMaxBackoff, *collectInterval)
+	}
+	if *webReadTimeout <= 0 {
+		return fmt.Errorf("-web.read-timeout must be positive, got %v", *webReadTimeout)
+	}
+	if *webWriteTimeout <= 0 {
+		return fmt.Errorf("-web.write-timeout must be positive, got %v", *webWriteTimeout)
+	}
+	if *maxConcurrency < 1 {
+		return fmt.Errorf("-ipmi.max-concurrency must be at least 1, got %d", *maxConcurrency)
+	}
+	return nil
 }
 
-func collectMetrics(config IPMIConfig) {
-	output, err := executeIPMICommand(config)
-	if err != nil {
-		log.Printf("Failed to execute IPMI command: %v", err)
+// validateWriteTimeout checks that -web.write-timeout leaves an /ipmi
+// collection with opts the time it can take at worst: every command of a BMC
+// that times out and is retried. A collection that doesn't fit is cut short
+// rather than failing, so the server only warns about it.
+func validateWriteTimeout(opts []ipmicollector.Option) error {
+	worstCase := ipmicollector.MaxRefreshDuration(opts...)
+	if *collectBMC {
+		worstCase += ipmicollector.MaxCommandDuration(opts...)
+	}
+	if *webWriteTimeout <= worstCase {
+		return fmt.Errorf("-web.write-timeout must be longer than the %v an /ipmi collection can take, got %v", worstCase, *webWriteTimeout)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("ipmi-exporter version %s (revision %s, built %s, %s)\n", version, revision, date, runtime.Version())
 		return
 	}
 
-	sensors := parseSensorData(output)
-	updateMetrics(sensors, config.Host)
-	log.Printf("Updated %d sensor metrics", len(sensors))
-}
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
 
-func startMetricsCollection(config IPMIConfig) {
-	ticker := time.NewTicker(30 * time.Second)
-	go func() {
-		for {
-			collectMetrics(config)
-			<-ticker.C
+	if err := validateFlags(); err != nil {
+		fatal("Invalid flags", "err", err)
+	}
+	var runner ipmicollector.CommandRunner = ipmicollector.LocalRunner{Path: *ipmiBinaryPath, MaxOutputBytes: *ipmiMaxOutputBytes}
+	if *ipmiFromFile != "" {
+		runner = ipmicollector.FileRunner{Path: *ipmiFromFile}
+	}
+	if *ipmiSSHRelay != "" {
+		sshRunner, err := ipmicollector.NewSSHRunner(*ipmiSSHRelay, *ipmiSSHKey, *ipmiSSHKnownHosts, *ipmiTimeout)
+		if err != nil {
+			fatal("Invalid -ipmi.ssh-relay", "err", err)
 		}
-	}()
+		sshRunner.MaxOutputBytes = *ipmiMaxOutputBytes
+		runner = sshRunner
+	}
+	var backend ipmicollector.SensorBackend
+	if *sensorBackend == "redfish" {
+		tlsConfig, err := redfishTLSConfig()
+		if err != nil {
+			fatal("Invalid Redfish TLS configuration", "err", err)
+		}
+		backend = ipmicollector.NewRedfishBackend(tlsConfig)
+	}
+	if local, ok := runner.(ipmicollector.LocalRunner); ok && backend == nil && (!*checkConfig || *checkConfigCollect) {
+		// Fail now instead of logging the same exec error on every cycle.
+		path, err := local.LookPath()
+		if err != nil {
+			fatal("ipmitool is not installed or not executable; install it or point -ipmi.binary-path at it", "err", err)
+		}
+		runner = ipmicollector.LocalRunner{Path: path, MaxOutputBytes: *ipmiMaxOutputBytes}
+	}
 
-	collectMetrics(config)
-}
+	registry := newRegistry()
+	exporterMetrics := ipmicollector.NewMetrics(*metricNamespace)
+	registry.MustRegister(exporterMetrics)
+	opts := collectorOptionsFromFlags(runner, backend, exporterMetrics)
+	if *checkConfig {
+		os.Exit(runConfigCheck(os.Stdout, *checkConfigCollect, opts))
+	}
+	if *oneshot {
+		registry.MustRegister(newBuildInfoGauge(*metricNamespace), newCollectorEnabledGauge(*metricNamespace))
+		os.Exit(runOneshot(os.Stdout, registry, opts))
+	}
 
-func main() {
-	fmt.Println("IPMI Prometheus Exporter starting...")
+	slog.Info("IPMI Prometheus Exporter starting", "version", version, "revision", revision)
+	if err := validateWriteTimeout(opts); err != nil {
+		slog.Warn("Slow /ipmi collections will be cut short", "err", err)
+	}
+	registry.MustRegister(
+		newBuildInfoGauge(*metricNamespace),
+		newCollectorEnabledGauge(*metricNamespace),
+		newCollectionIntervalGauge(*metricNamespace, *collectInterval),
+	)
 
-	config := getIPMIConfig()
-	log.Printf("Connecting to IPMI host: %s", config.Host)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	startMetricsCollection(config)
+	fileConfig, targets, err := loadTargets()
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
 
-	http.Handle("/metrics", promhttp.Handler())
+	health := newReadiness(len(targets) > 0)
+	if len(targets) == 0 {
+		slog.Info("No IPMI targets configured, background collection disabled; use /ipmi?target=<host> to scrape")
+	}
+	running := newTargetSet(ctx, registry, opts, exporterMetrics, health, fileConfig, targets)
+	if len(targets) > 0 {
+		startMetricsCollection(ctx, running.collectors, *collectInterval, *collectJitter, health)
+		if *collectBMC {
+			startBMCInfoCollection(ctx, running.collectors, *collectBMCInterval)
+		}
+	}
+	if *configFile != "" {
+		running.reloadOnSIGHUP(ctx)
+	}
+	var stopOTLP func(context.Context) error
+	if *otlpEndpoint != "" {
+		stopOTLP, err = startOTLPExport(ctx, *otlpEndpoint, *collectInterval, registry, *metricNamespace)
+		if err != nil {
+			fatal("Failed to set up OTLP export", "endpoint", *otlpEndpoint, "err", err)
+		}
+		slog.Info("Pushing IPMI metrics over OTLP", "endpoint", *otlpEndpoint)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*telemetryPath, promhttp.InstrumentMetricHandler(registry, metricsHandler(registry)))
+	mux.Handle("/ipmi", ipmiHandler(running, opts, newAdhocMetrics(*metricNamespace, adhocMetricsTTL)))
+	mux.HandleFunc("/-/reload", running.reloadHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", health.readyzHandler)
+	mux.HandleFunc("/", landingPageHandler)
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	server := newServer(mux, *webReadTimeout, *webWriteTimeout)
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := listenAndServe(server, *listenAddress, *webConfigFile, logger); err != nil && err != http.ErrServerClosed {
+			fatal("HTTP server failed", "err", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server shutdown failed", "err", err)
+	}
+	if stopOTLP != nil {
+		if err := stopOTLP(shutdownCtx); err != nil {
+			slog.Error("OTLP export shutdown failed", "err", err)
+		}
+	}
+	// Wait for the listener to be torn down so a Unix socket is removed.
+	<-served
 }
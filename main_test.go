@@ -0,0 +1,977 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+const sdrFixture = "ipmicollector/testdata/sdr_elist.txt"
+
+// setFlags sets the command-line flags in values for the rest of the test
+// and restores them when it ends.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Fatalf("no flag -%s", name)
+		}
+		previous := f.Value.String()
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("-%s=%s: %v", name, value, err)
+		}
+		t.Cleanup(func() { _ = flag.Set(name, previous) })
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		ipmiHost string
+		wantErr  string
+	}{
+		{name: "defaults"},
+		{name: "short interval", flags: map[string]string{"collect.interval": "500ms"}, wantErr: "-collect.interval"},
+		{name: "jitter over interval", flags: map[string]string{"collect.jitter": "30s"}, wantErr: "-collect.jitter"},
+		{name: "unknown interface", flags: map[string]string{"ipmi.interface": "serial"}, wantErr: "-ipmi.interface"},
+		{name: "bad namespace", flags: map[string]string{"metric.namespace": "1acme"}, wantErr: "-metric.namespace"},
+		{name: "bad filter", flags: map[string]string{"collect.include": "("}, wantErr: "sensor filter"},
+		{name: "type queries without types", flags: map[string]string{"collect.sdr-query": "type"}, wantErr: "-collect.sdr-query=type"},
+		{name: "type queries", flags: map[string]string{"collect.sdr-query": "type", "collect.types": "temperature,fan"}},
+		{name: "type queries from file", flags: map[string]string{"collect.sdr-query": "type", "collect.types": "temperature", "ipmi.from-file": sdrFixture}, wantErr: "-ipmi.from-file"},
+		{name: "unknown backend", flags: map[string]string{"backend": "snmp"}, wantErr: "-backend"},
+		{name: "redfish with sel", flags: map[string]string{"backend": "redfish", "collect.sel": "true"}, wantErr: "-backend=redfish"},
+		{name: "from file and ssh relay", flags: map[string]string{"ipmi.from-file": sdrFixture, "ipmi.ssh-relay": "jump:22"}, wantErr: "mutually exclusive"},
+		{name: "reserved telemetry path", flags: map[string]string{"web.telemetry-path": "/ipmi"}, wantErr: "-web.telemetry-path"},
+		{name: "relative telemetry path", flags: map[string]string{"web.telemetry-path": "metrics"}, wantErr: "-web.telemetry-path"},
+		{name: "otlp endpoint without scheme", flags: map[string]string{"output.otlp-endpoint": "collector:4318"}, wantErr: "-output.otlp-endpoint"},
+		{name: "otlp endpoint", flags: map[string]string{"output.otlp-endpoint": "http://collector:4318"}},
+		{name: "circuit breaker backoff under interval", flags: map[string]string{"collect.circuit-breaker-failures": "3", "collect.circuit-breaker-max-backoff": "10s"}, wantErr: "-collect.circuit-breaker-max-backoff"},
+		{name: "no write timeout", flags: map[string]string{"web.write-timeout": "0s"}, wantErr: "-web.write-timeout"},
+		{name: "no concurrency", flags: map[string]string{"ipmi.max-concurrency": "0"}, wantErr: "-ipmi.max-concurrency"},
+		{name: "several hosts", ipmiHost: "bmc1, bmc2,bmc3"},
+		{name: "empty host", ipmiHost: "bmc1,,bmc3", wantErr: "IPMI_HOST entry 2 is empty"},
+		{name: "trailing comma", ipmiHost: "bmc1,", wantErr: "IPMI_HOST entry 2 is empty"},
+		{name: "duplicate host", ipmiHost: "bmc1,bmc2,bmc1", wantErr: "more than once"},
+		{name: "ipv6 hosts", ipmiHost: "[fe80::1],2001:db8::1"},
+		{name: "host with port", ipmiHost: "bmc1,[::1]:623", wantErr: "IPMI_HOST entry 2: host \"[::1]:623\" must not include a port"},
+		{name: "instance name for several hosts", flags: map[string]string{"ipmi.instance-name": "web-01"}, ipmiHost: "bmc1,bmc2", wantErr: "-ipmi.instance-name"},
+		{name: "hosts named by the config file", flags: map[string]string{"config.file": "ipmi.yml"}, ipmiHost: "bmc1,,bmc3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			t.Setenv("IPMI_HOST", tt.ipmiHost)
+			err := validateFlags()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateFlags() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateFlags() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetIPMIConfigs(t *testing.T) {
+	t.Setenv("IPMI_HOST", "bmc1, bmc2,10.0.0.3")
+	t.Setenv("IPMI_USERNAME", "admin")
+	t.Setenv("IPMI_PASSWORD", "secret")
+	t.Setenv("IPMI_PASSWORD_FILE", "")
+	configs, err := getIPMIConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts []string
+	for _, config := range configs {
+		hosts = append(hosts, config.Host)
+		if config.Username != "admin" || config.Password != "secret" || config.Port != ipmicollector.DefaultPort {
+			t.Errorf("target %s = %+v, want the shared credentials and the default port", config.Host, config)
+		}
+	}
+	if want := []string{"bmc1", "bmc2", "10.0.0.3"}; !slices.Equal(hosts, want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+
+	t.Setenv("IPMI_HOST", "bmc1,,bmc3")
+	if _, err := getIPMIConfigs(); err == nil || !strings.Contains(err.Error(), "entry 2 is empty") {
+		t.Errorf("getIPMIConfigs() = %v, want an error about the empty entry", err)
+	}
+
+	setFlags(t, map[string]string{"ipmi.instance-name": "web-prod-01"})
+	t.Setenv("IPMI_HOST", "10.0.0.10")
+	configs, err = getIPMIConfigs()
+	if err != nil || len(configs) != 1 || configs[0].InstanceName != "web-prod-01" {
+		t.Errorf("getIPMIConfigs() with -ipmi.instance-name = %+v, %v; want the instance name set", configs, err)
+	}
+}
+
+func TestGetIPMIPassword(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		password     string
+		passwordFile string
+		want         string
+		wantErr      bool
+	}{
+		{name: "env", password: "from-env", want: "from-env"},
+		{name: "file over env", password: "from-env", passwordFile: passwordFile, want: "from-file"},
+		{name: "file only", passwordFile: passwordFile, want: "from-file"},
+		{name: "missing file", password: "from-env", passwordFile: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "neither"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IPMI_PASSWORD", tt.password)
+			t.Setenv("IPMI_PASSWORD_FILE", tt.passwordFile)
+			got, err := getIPMIPassword()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getIPMIPassword() = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getIPMIPassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"bmc1.user": "admin\n", "bmc1.pass": "secret\r\n", "bmc2.user": "root\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		config  ipmicollector.IPMIConfig
+		want    ipmicollector.IPMIConfig
+		wantErr string
+	}{
+		{
+			name:   "from files",
+			config: ipmicollector.IPMIConfig{Host: "bmc1"},
+			want:   ipmicollector.IPMIConfig{Host: "bmc1", Username: "admin", Password: "secret"},
+		},
+		{
+			name:   "configured credentials first",
+			config: ipmicollector.IPMIConfig{Host: "bmc1", Password: "configured"},
+			want:   ipmicollector.IPMIConfig{Host: "bmc1", Username: "admin", Password: "configured"},
+		},
+		{
+			name:    "missing file",
+			config:  ipmicollector.IPMIConfig{Host: "bmc2"},
+			wantErr: "failed to read credential file from " + dir,
+		},
+		{
+			name:    "host outside the directory",
+			config:  ipmicollector.IPMIConfig{Host: "../bmc1"},
+			wantErr: "invalid host",
+		},
+		{
+			name:    "hidden file",
+			config:  ipmicollector.IPMIConfig{Host: ".bmc1"},
+			wantErr: "invalid host",
+		},
+		{
+			name:   "open interface",
+			config: ipmicollector.IPMIConfig{Host: "bmc3", Interface: "open"},
+			want:   ipmicollector.IPMIConfig{Host: "bmc3", Interface: "open"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			err := resolveCredentials(&config, dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveCredentials() = %v, want an error about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config != tt.want {
+				t.Errorf("resolveCredentials() = %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostEnvName(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"bmc1", "IPMI_PASSWORD_BMC1"},
+		{"bmc-1.example.com", "IPMI_PASSWORD_BMC_1_EXAMPLE_COM"},
+		{"10.0.0.10", "IPMI_PASSWORD_10_0_0_10"},
+		{"fe80::1", "IPMI_PASSWORD_FE80__1"},
+	}
+	for _, tt := range tests {
+		if got := hostEnvName("IPMI_PASSWORD", tt.host); got != tt.want {
+			t.Errorf("hostEnvName(%s) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGetIPMIConfigsPerHostCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantUsername map[string]string
+		wantPassword map[string]string
+		wantErr      string
+	}{
+		{
+			name:         "shared",
+			env:          map[string]string{"IPMI_USERNAME": "admin", "IPMI_PASSWORD": "secret"},
+			wantUsername: map[string]string{"bmc-1.example.com": "admin", "bmc2": "admin"},
+			wantPassword: map[string]string{"bmc-1.example.com": "secret", "bmc2": "secret"},
+		},
+		{
+			name: "per host",
+			env: map[string]string{
+				"IPMI_USERNAME":                   "admin",
+				"IPMI_PASSWORD":                   "secret",
+				"IPMI_USER_BMC_1_EXAMPLE_COM":     "root",
+				"IPMI_PASSWORD_BMC_1_EXAMPLE_COM": "calvin",
+			},
+			wantUsername: map[string]string{"bmc-1.example.com": "root", "bmc2": "admin"},
+			wantPassword: map[string]string{"bmc-1.example.com": "calvin", "bmc2": "secret"},
+		},
+		{
+			// Per-host variables set but empty override the shared ones.
+			name:    "empty per host password",
+			env:     map[string]string{"IPMI_USERNAME": "admin", "IPMI_PASSWORD": "secret", "IPMI_PASSWORD_BMC2": ""},
+			wantErr: "IPMI_PASSWORD_BMC2",
+		},
+		{
+			name: "per host only",
+			env: map[string]string{
+				"IPMI_USER_BMC_1_EXAMPLE_COM":     "root",
+				"IPMI_PASSWORD_BMC_1_EXAMPLE_COM": "calvin",
+				"IPMI_USER_BMC2":                  "admin",
+				"IPMI_PASSWORD_BMC2":              "secret",
+			},
+			wantUsername: map[string]string{"bmc-1.example.com": "root", "bmc2": "admin"},
+			wantPassword: map[string]string{"bmc-1.example.com": "calvin", "bmc2": "secret"},
+		},
+		{
+			name:    "missing for one host",
+			env:     map[string]string{"IPMI_USER_BMC_1_EXAMPLE_COM": "root", "IPMI_PASSWORD_BMC_1_EXAMPLE_COM": "calvin"},
+			wantErr: "no credentials for bmc2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IPMI_HOST", "bmc-1.example.com,bmc2")
+			for _, name := range []string{"IPMI_USERNAME", "IPMI_PASSWORD", "IPMI_PASSWORD_FILE"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			configs, err := getIPMIConfigs()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("getIPMIConfigs() = %v, want an error about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, config := range configs {
+				if config.Username != tt.wantUsername[config.Host] || config.Password != tt.wantPassword[config.Host] {
+					t.Errorf("%s: credentials %s/%s, want %s/%s", config.Host, config.Username, config.Password, tt.wantUsername[config.Host], tt.wantPassword[config.Host])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateWriteTimeout(t *testing.T) {
+	// With a 1s timeout and no retries, every command takes at most 1s.
+	opts := []ipmicollector.Option{ipmicollector.WithTimeout(time.Second), ipmicollector.WithRetries(0)}
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		opts    []ipmicollector.Option
+		wantErr bool
+	}{
+		{name: "defaults", flags: map[string]string{}},
+		{name: "sdr only", flags: map[string]string{"web.write-timeout": "1500ms"}, opts: opts},
+		{name: "sdr at the limit", flags: map[string]string{"web.write-timeout": "1s"}, opts: opts, wantErr: true},
+		{name: "thresholds and sel", flags: map[string]string{"web.write-timeout": "2500ms"}, opts: append(opts, ipmicollector.WithThresholds(), ipmicollector.WithSEL()), wantErr: true},
+		{name: "bmc info", flags: map[string]string{"web.write-timeout": "1500ms", "collect.bmc-info": "true"}, opts: opts, wantErr: true},
+		{name: "default retries", flags: map[string]string{"web.write-timeout": "30s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			if err := validateWriteTimeout(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("validateWriteTimeout() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteTimeoutIgnoredWithoutServer(t *testing.T) {
+	// The collectors enabled here take longer at worst than the default
+	// write timeout, which only matters to /ipmi.
+	setFlags(t, map[string]string{
+		"ipmi.from-file":     sdrFixture,
+		"collect.thresholds": "true",
+		"collect.dcmi":       "true",
+		"collect.sel":        "true",
+		"collect.chassis":    "true",
+	})
+	t.Setenv("IPMI_HOST", "")
+	opts := collectorOptionsFromFlags(ipmicollector.FileRunner{Path: sdrFixture}, nil, ipmicollector.NewMetrics(""))
+	if err := validateWriteTimeout(opts); err == nil {
+		t.Fatal("validateWriteTimeout() = nil, want an error for these collectors")
+	}
+
+	if code := runConfigCheck(io.Discard, true, opts); code != 0 {
+		t.Errorf("runConfigCheck() = %d, want 0", code)
+	}
+	if code := runOneshot(io.Discard, prometheus.NewRegistry(), opts); code != 0 {
+		t.Errorf("runOneshot() = %d, want 0", code)
+	}
+}
+
+func TestRunOneshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		fixture  string
+		wantCode int
+		want     []string
+	}{
+		{
+			name:    "fixture",
+			flags:   map[string]string{"ipmi.from-file": sdrFixture},
+			fixture: sdrFixture,
+			want: []string{
+				`ipmi_up{host="localhost",instance_name="localhost"} 1`,
+				`ipmi_temperature_celsius{host="localhost",instance_name="localhost",sensor_id="01h",sensor_name="CPU Temp"} 45`,
+			},
+		},
+		{
+			name:     "failed collection",
+			flags:    map[string]string{"ipmi.from-file": "testdata/missing.txt"},
+			fixture:  "testdata/missing.txt",
+			wantCode: 1,
+			want:     []string{`ipmi_up{host="localhost",instance_name="localhost"} 0`},
+		},
+		{
+			name:     "invalid config",
+			flags:    map[string]string{"config.file": filepath.Join(t.TempDir(), "missing.yml")},
+			fixture:  sdrFixture,
+			wantCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			t.Setenv("IPMI_HOST", "")
+			opts := []ipmicollector.Option{ipmicollector.WithRunner(ipmicollector.FileRunner{Path: tt.fixture})}
+			var out strings.Builder
+			if code := runOneshot(&out, prometheus.NewRegistry(), opts); code != tt.wantCode {
+				t.Errorf("runOneshot() = %d, want %d", code, tt.wantCode)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output is missing %s:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+// fixtureOptions returns collector options that serve the sdr fixture in
+// place of ipmitool.
+func fixtureOptions() []ipmicollector.Option {
+	return []ipmicollector.Option{ipmicollector.WithRunner(ipmicollector.FileRunner{Path: sdrFixture})}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	collector := newCollector(ipmicollector.IPMIConfig{Host: "bmc1"}, fixtureOptions())
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	handler := metricsHandler(registry)
+
+	tests := []struct {
+		name            string
+		accept          string
+		acceptEncoding  string
+		wantContentType string
+		wantEncoding    string
+	}{
+		{name: "text", wantContentType: "text/plain"},
+		{name: "openmetrics", accept: "application/openmetrics-text; version=1.0.0", wantContentType: "application/openmetrics-text"},
+		{name: "gzip", acceptEncoding: "gzip", wantContentType: "text/plain", wantEncoding: "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %s", contentType, tt.wantContentType)
+			}
+			if encoding := rec.Header().Get("Content-Encoding"); encoding != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			if tt.wantEncoding == "" && !strings.Contains(rec.Body.String(), `ipmi_up{host="bmc1",instance_name="bmc1"} 1`) {
+				t.Errorf("body lacks ipmi_up of bmc1:\n%s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	rec := httptest.NewRecorder()
+	metricsHandler(newRegistry()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, metric := range []string{"go_goroutines", "go_memstats_alloc_bytes", "process_start_time_seconds"} {
+		if !strings.Contains(rec.Body.String(), "\n"+metric+" ") {
+			t.Errorf("/metrics is missing %s", metric)
+		}
+	}
+}
+
+func TestIPMIHandler(t *testing.T) {
+	opts := fixtureOptions()
+	targets := newTargetSet(context.Background(), prometheus.NewRegistry(), opts, ipmicollector.NewMetrics(""), newReadiness(false), nil,
+		[]ipmicollector.IPMIConfig{{Host: "bmc1", Username: "admin", Password: "secret"}})
+	handler := ipmiHandler(targets, opts, newAdhocMetrics("", adhocMetricsTTL))
+	t.Setenv("IPMI_USERNAME", "env-admin")
+	t.Setenv("IPMI_PASSWORD", "env-secret")
+
+	tests := []struct {
+		name       string
+		query      string
+		flags      map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "no target", query: "", wantStatus: http.StatusBadRequest, wantBody: "'target' parameter"},
+		{name: "configured target", query: "target=bmc1", wantStatus: http.StatusOK, wantBody: `ipmi_up{host="bmc1",instance_name="bmc1"} 1`},
+		{name: "ad-hoc target without credentials", query: "target=bmc2", wantStatus: http.StatusBadRequest, wantBody: "'username' and 'password'"},
+		{name: "ad-hoc target", query: "target=bmc2&username=admin&password=secret", wantStatus: http.StatusOK, wantBody: `ipmi_up{host="bmc2",instance_name="bmc2"} 1`},
+		{name: "ad-hoc target with env credentials", query: "target=bmc2", flags: map[string]string{"ipmi.ad-hoc-env-credentials": "true"}, wantStatus: http.StatusOK, wantBody: `ipmi_up{host="bmc2",instance_name="bmc2"} 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			req := httptest.NewRequest(http.MethodGet, "/ipmi?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body lacks %s:\n%s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestIPMIHandlerCountersAccumulate(t *testing.T) {
+	opts := fixtureOptions()
+	targets := newTargetSet(context.Background(), prometheus.NewRegistry(), opts, ipmicollector.NewMetrics(""), newReadiness(false), nil, nil)
+	handler := ipmiHandler(targets, opts, newAdhocMetrics("", adhocMetricsTTL))
+
+	// The fixture has one unparsable sensor, counted on every scrape.
+	for scrape, target := range []string{"bmc1", "bmc2", "bmc1"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ipmi?username=admin&password=secret&target="+target, nil))
+		if scrape < 2 {
+			continue
+		}
+		body := rec.Body.String()
+		if want := `ipmi_sensor_parse_errors_total{host="bmc1",instance_name="bmc1"} 2`; !strings.Contains(body, want) {
+			t.Errorf("body lacks %s:\n%s", want, body)
+		}
+		if strings.Contains(body, `host="bmc2"`) {
+			t.Errorf("body has series of bmc2:\n%s", body)
+		}
+	}
+}
+
+func TestAdhocMetricsExpire(t *testing.T) {
+	counters := newAdhocMetrics("", time.Hour)
+	bmc1 := ipmicollector.IPMIConfig{Host: "bmc1"}
+	bmc2 := ipmicollector.IPMIConfig{Host: "bmc2"}
+	start := time.Now()
+
+	first := counters.get(bmc1, start)
+	if got := counters.get(bmc1, start.Add(59*time.Minute)); got != first {
+		t.Error("the counters of a target scraped within the ttl were replaced")
+	}
+	counters.get(bmc2, start.Add(2*time.Hour))
+	if len(counters.targets) != 1 {
+		t.Errorf("%d targets kept, want only bmc2", len(counters.targets))
+	}
+	if got := counters.get(bmc1, start.Add(2*time.Hour)); got == first {
+		t.Error("the counters of a target idle past the ttl were kept")
+	}
+}
+
+// blockingRunner is a CommandRunner whose commands hang until they are
+// cancelled, like those sent to an unresponsive BMC.
+type blockingRunner struct{}
+
+// Run implements ipmicollector.CommandRunner.
+func (blockingRunner) Run(ctx context.Context, _ []string, _ string) ([]byte, []byte, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func TestIPMIHandlerWriteTimeout(t *testing.T) {
+	setFlags(t, map[string]string{"web.write-timeout": "400ms"})
+	opts := []ipmicollector.Option{ipmicollector.WithRunner(blockingRunner{})}
+	targets := newTargetSet(context.Background(), prometheus.NewRegistry(), opts, ipmicollector.NewMetrics(""), newReadiness(false), nil, nil)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	ipmiHandler(targets, opts, newAdhocMetrics("", adhocMetricsTTL)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ipmi?target=bmc1&username=admin&password=secret", nil))
+	if elapsed := time.Since(start); elapsed >= 400*time.Millisecond {
+		t.Errorf("the collection took %v, past the write timeout", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if want := `ipmi_up{host="bmc1",instance_name="bmc1"} 0`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("body lacks %s:\n%s", want, rec.Body)
+	}
+}
+
+// heldRunner serves the sdr fixture. Once hold is set, every command
+// signals on started and waits for release.
+type heldRunner struct {
+	hold    atomic.Bool
+	started chan struct{}
+	release chan struct{}
+}
+
+// Run implements ipmicollector.CommandRunner.
+func (r *heldRunner) Run(ctx context.Context, args []string, stdin string) ([]byte, []byte, error) {
+	if r.hold.Load() {
+		r.started <- struct{}{}
+		<-r.release
+	}
+	return ipmicollector.FileRunner{Path: sdrFixture}.Run(ctx, args, stdin)
+}
+
+func TestIPMIHandlerDuringBackgroundCollection(t *testing.T) {
+	runner := &heldRunner{started: make(chan struct{}), release: make(chan struct{})}
+	opts := []ipmicollector.Option{ipmicollector.WithRunner(runner)}
+	targets := newTargetSet(context.Background(), prometheus.NewRegistry(), opts, ipmicollector.NewMetrics(""), newReadiness(true), nil,
+		[]ipmicollector.IPMIConfig{{Host: "bmc1", Username: "admin", Password: "secret"}})
+	background := targets.collectors()[0]
+	if err := background.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next background collection hangs on the BMC, as does an /ipmi
+	// scrape of bmc2.
+	runner.hold.Store(true)
+	handler := ipmiHandler(targets, opts, newAdhocMetrics("", adhocMetricsTTL))
+	done := make(chan struct{})
+	go func() {
+		defer func() { done <- struct{}{} }()
+		_ = background.Refresh(context.Background())
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ipmi?target=bmc2&username=admin&password=secret", nil))
+	}()
+	<-runner.started
+	<-runner.started
+	defer func() {
+		close(runner.release)
+		<-done
+		<-done
+	}()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantBody []string
+	}{
+		{
+			name:  "configured target",
+			query: "target=bmc1",
+			wantBody: []string{
+				`ipmi_up{host="bmc1",instance_name="bmc1"} 1`,
+				`ipmi_temperature_celsius{host="bmc1",instance_name="bmc1",sensor_id="01h",sensor_name="CPU Temp"} 45`,
+			},
+		},
+		{
+			name:     "ad-hoc target",
+			query:    "target=bmc2&username=admin&password=secret",
+			wantBody: []string{`ipmi_up{host="bmc2",instance_name="bmc2"} 0`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ipmi?"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			for _, want := range tt.wantBody {
+				if body := rec.Body.String(); !strings.Contains(body, want) {
+					t.Errorf("body lacks %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		background bool
+		results    []error
+		wantStatus int
+	}{
+		{name: "no background collection", wantStatus: http.StatusOK},
+		{name: "no collection yet", background: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "collected", background: true, results: []error{nil}, wantStatus: http.StatusOK},
+		{name: "failures under the limit", background: true, results: []error{nil, io.EOF, io.EOF}, wantStatus: http.StatusOK},
+		{name: "failures at the limit", background: true, results: []error{nil, io.EOF, io.EOF, io.EOF}, wantStatus: http.StatusServiceUnavailable},
+		{name: "recovered", background: true, results: []error{nil, io.EOF, io.EOF, io.EOF, nil}, wantStatus: http.StatusOK},
+		{name: "never collected", background: true, results: []error{io.EOF}, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := newReadiness(tt.background)
+			for _, err := range tt.results {
+				health.record(err)
+			}
+			rec := httptest.NewRecorder()
+			health.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	working := newCollector(ipmicollector.IPMIConfig{Host: "bmc1"}, fixtureOptions())
+	failing := newCollector(ipmicollector.IPMIConfig{Host: "bmc2"},
+		[]ipmicollector.Option{ipmicollector.WithRunner(ipmicollector.FileRunner{Path: "testdata/missing.txt"})})
+
+	tests := []struct {
+		name       string
+		collectors []*ipmicollector.Collector
+		want       bool
+	}{
+		{name: "one host failing", collectors: []*ipmicollector.Collector{failing, working}, want: true},
+		{name: "every host failing", collectors: []*ipmicollector.Collector{failing}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := newReadiness(true)
+			health.record(nil)
+			for range maxConsecutiveFailures {
+				collectAll(context.Background(), tt.collectors, 1, health)
+			}
+			if got := health.ready(); got != tt.want {
+				t.Errorf("ready() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectAllConcurrency(t *testing.T) {
+	const hosts, maxConcurrency = 5, 3
+	runner := &heldRunner{started: make(chan struct{}, hosts), release: make(chan struct{})}
+	runner.hold.Store(true)
+	opts := []ipmicollector.Option{ipmicollector.WithRunner(runner)}
+	var collectors []*ipmicollector.Collector
+	for i := range hosts {
+		collectors = append(collectors, newCollector(ipmicollector.IPMIConfig{Host: fmt.Sprintf("bmc%d", i+1)}, opts))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		collectAll(context.Background(), collectors, maxConcurrency, newReadiness(true))
+	}()
+	for range maxConcurrency {
+		select {
+		case <-runner.started:
+		case <-time.After(time.Second):
+			t.Fatalf("fewer than %d hosts collected at once", maxConcurrency)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(runner.started); n != 0 {
+		t.Errorf("%d more hosts collected while %d are in flight", n, maxConcurrency)
+	}
+
+	close(runner.release)
+	<-done
+	if n := len(runner.started); n != hosts-maxConcurrency {
+		t.Errorf("%d hosts collected after the first ones finished, want %d", n, hosts-maxConcurrency)
+	}
+	for _, collector := range collectors {
+		if len(collector.Sensors()) == 0 {
+			t.Errorf("no sensors collected from %s", collector.HostLabel())
+		}
+	}
+}
+
+// exportedHosts returns the hosts registry exports ipmi_up for.
+func exportedHosts(t *testing.T, registry *prometheus.Registry) []string {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts []string
+	for _, family := range families {
+		if family.GetName() != "ipmi_up" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "host" {
+					hosts = append(hosts, label.GetValue())
+				}
+			}
+		}
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+func TestReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "ipmi.yml")
+	writeConfig := func(hosts ...string) {
+		var config strings.Builder
+		config.WriteString("targets:\n")
+		for _, host := range hosts {
+			config.WriteString("  - host: " + host + "\n    username: admin\n    password: secret\n")
+		}
+		if err := os.WriteFile(configPath, []byte(config.String()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("bmc1", "bmc2")
+	setFlags(t, map[string]string{"config.file": configPath})
+	fileConfig, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	registry := prometheus.NewRegistry()
+	metrics := ipmicollector.NewMetrics("")
+	opts := append(fixtureOptions(), ipmicollector.WithMetrics(metrics))
+	targets := newTargetSet(ctx, registry, opts, metrics, newReadiness(true), fileConfig, fileConfig.Targets)
+	kept, _ := targets.target("bmc2")
+	keptCollector, _ := targets.collector(kept)
+
+	writeConfig("bmc2", "bmc3")
+	if err := targets.reload(); err != nil {
+		t.Fatalf("reload() = %v", err)
+	}
+	if got, want := exportedHosts(t, registry), []string{"bmc2", "bmc3"}; !slices.Equal(got, want) {
+		t.Errorf("exported hosts after reload = %v, want %v", got, want)
+	}
+	if _, ok := targets.target("bmc1"); ok {
+		t.Error("target(bmc1) found after it was removed")
+	}
+	if _, ok := targets.target("bmc3"); !ok {
+		t.Error("target(bmc3) not found after it was added")
+	}
+	if collector, _ := targets.collector(kept); collector != keptCollector {
+		t.Error("reload() replaced the collector of the unchanged bmc2")
+	}
+
+	// A broken config file leaves the targets as they were.
+	if err := os.WriteFile(configPath, []byte("targets: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := targets.reload(); err == nil {
+		t.Error("reload() of a config without targets = nil, want an error")
+	}
+	if got := len(targets.collectors()); got != 2 {
+		t.Errorf("%d targets after a failed reload, want 2", got)
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	const jitter = 10 * time.Millisecond
+	var longest time.Duration
+	for range 1000 {
+		delay := jitterDelay(jitter)
+		if delay < 0 || delay >= jitter {
+			t.Fatalf("jitterDelay(%v) = %v, want it in [0, %v)", jitter, delay, jitter)
+		}
+		longest = max(longest, delay)
+	}
+	// The delays spread over the range rather than sticking to one end.
+	if longest < jitter/2 {
+		t.Errorf("longest of 1000 delays = %v, want the range covered", longest)
+	}
+	for _, jitter := range []time.Duration{0, -time.Second} {
+		if delay := jitterDelay(jitter); delay != 0 {
+			t.Errorf("jitterDelay(%v) = %v, want 0", jitter, delay)
+		}
+	}
+}
+
+func TestSleepJitter(t *testing.T) {
+	if !sleepJitter(context.Background(), time.Millisecond) {
+		t.Error("sleepJitter() = false, want true once the delay has passed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if sleepJitter(ctx, time.Hour) {
+		t.Error("sleepJitter() with a cancelled context = true, want false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepJitter() with a cancelled context returned after %v", elapsed)
+	}
+	if sleepJitter(ctx, 0) {
+		t.Error("sleepJitter() without jitter and a cancelled context = true, want false")
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(dir, "metrics.txt")
+		if err := os.WriteFile(path, []byte("keep me"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if listener, err := listenUnix(path); err == nil {
+			_ = listener.Close()
+			t.Fatal("listenUnix() on a regular file = nil, want an error")
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != "keep me" {
+			t.Errorf("the file was changed: %q, %v", data, err)
+		}
+	})
+
+	t.Run("stale socket", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		stale, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		_ = stale.Close()
+
+		listener, err := listenUnix(path)
+		if err != nil {
+			t.Fatalf("listenUnix() = %v, want the stale socket replaced", err)
+		}
+		_ = listener.Close()
+	})
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	collector := newCollector(ipmicollector.IPMIConfig{Host: "bmc1"}, fixtureOptions())
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(registry))
+	server := newServer(mux, time.Minute, time.Minute)
+
+	path := filepath.Join(t.TempDir(), "ipmi.sock")
+	served := make(chan error, 1)
+	go func() {
+		served <- listenAndServe(server, unixSocketPrefix+path, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}()
+	defer func() {
+		_ = server.Close()
+		<-served
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = client.Get("http://ipmi-exporter/metrics"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `ipmi_up{host="bmc1",instance_name="bmc1"} 1`) {
+		t.Errorf("GET /metrics over the socket = %d:\n%s", resp.StatusCode, body)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != unixSocketMode {
+		t.Errorf("socket mode = %v, want %v", mode, os.FileMode(unixSocketMode))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+// runOneshot collects once from every configured target for -oneshot and
+// writes the metrics of registry, with the targets registered, to w in the
+// Prometheus text format. It returns the process exit code: non-zero if the
+// configuration is invalid, no target is configured or a target could not be
+// collected from, or if the metrics could not be written. The metrics are
+// written in any case once collected, so a failed target shows up as
+// ipmi_up 0.
+func runOneshot(w io.Writer, registry *prometheus.Registry, opts []ipmicollector.Option) int {
+	_, targets, err := loadTargets()
+	if err != nil {
+		slog.Error("Invalid configuration", "err", err)
+		return 1
+	}
+	if len(targets) == 0 {
+		slog.Error("-oneshot requires at least one target from IPMI_HOST or -config.file")
+		return 1
+	}
+
+	collectors := make([]*ipmicollector.Collector, 0, len(targets))
+	for _, target := range targets {
+		collector := newCollector(target, opts)
+		registry.MustRegister(collector)
+		collectors = append(collectors, collector)
+	}
+	if *collectBMC {
+		refreshBMCInfo(context.Background(), collectors)
+	}
+	failed := 0
+	for _, collector := range collectors {
+		if err := collector.Refresh(context.Background()); err != nil {
+			failed++
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		slog.Error("Failed to gather metrics", "err", err)
+		return 1
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			slog.Error("Failed to write metrics", "err", err)
+			return 1
+		}
+	}
+
+	if failed > 0 {
+		slog.Error("Collection failed", "failed", failed, "targets", len(targets))
+		return 1
+	}
+	return 0
+}
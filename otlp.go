@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// otlpScope is the instrumentation scope the pushed metrics belong to.
+var otlpScope = instrumentation.Scope{Name: "github.com/wimwenigerkind/ipmi-prometheus-exporter"}
+
+// otlpUnits maps the unit suffixes of the metric names to OTLP units.
+var otlpUnits = []struct {
+	suffix string
+	unit   string
+}{
+	{"_volts", "V"},
+	{"_celsius", "Cel"},
+	{"_fahrenheit", "[degF]"},
+	{"_kelvin", "K"},
+	{"_rpm", "{rpm}"},
+	{"_percent", "%"},
+	{"_watts", "W"},
+	{"_amperes", "A"},
+	{"_joules", "J"},
+	{"_seconds", "s"},
+}
+
+// startOTLPExport pushes the IPMI metrics gathered from gatherer to the
+// OTLP/HTTP endpoint every interval, e.g. http://otel-collector:4318. Only the
+// metric families named with the IPMI prefix of namespace are pushed, so
+// they are those served on /metrics, from the last background collection and
+// with -collect.types and the temperature unit applied. The returned function
+// stops the export after flushing the last metrics.
+func startOTLPExport(ctx context.Context, endpoint string, interval time.Duration, gatherer prometheus.Gatherer, namespace string) (func(context.Context) error, error) {
+	exporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Error("OTLP export failed", "endpoint", endpoint, "err", err)
+	}))
+
+	producer := &gathererProducer{
+		gatherer: gatherer,
+		prefix:   prometheus.BuildFQName(namespace, "", "ipmi_"),
+		start:    time.Now(),
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval), sdkmetric.WithProducer(producer))),
+		sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", "ipmi-exporter"))),
+	)
+	return provider.Shutdown, nil
+}
+
+// gathererProducer turns the gauges and counters gathered from a Prometheus
+// registry into OTLP metrics, with the labels of a series as its attributes.
+type gathererProducer struct {
+	gatherer prometheus.Gatherer
+	// prefix selects the metric families to push by name.
+	prefix string
+	// start is the start time of the counters, which accumulate for the
+	// life of the process.
+	start time.Time
+}
+
+// Produce implements sdkmetric.Producer. Families that fail to gather are
+// left out and the others are pushed.
+func (p *gathererProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	families, err := p.gatherer.Gather()
+	now := time.Now()
+
+	var metrics []metricdata.Metrics
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), p.prefix) {
+			continue
+		}
+		m := metricdata.Metrics{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+			Unit:        otlpUnit(family.GetName()),
+		}
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			var gauge metricdata.Gauge[float64]
+			for _, series := range family.GetMetric() {
+				gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: otlpAttributes(series),
+					Time:       otlpTime(series, now),
+					Value:      series.GetGauge().GetValue(),
+				})
+			}
+			m.Data = gauge
+		case dto.MetricType_COUNTER:
+			sum := metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+			for _, series := range family.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: otlpAttributes(series),
+					StartTime:  p.start,
+					Time:       otlpTime(series, now),
+					Value:      series.GetCounter().GetValue(),
+				})
+			}
+			m.Data = sum
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	if len(metrics) == 0 {
+		return nil, err
+	}
+	return []metricdata.ScopeMetrics{{Scope: otlpScope, Metrics: metrics}}, err
+}
+
+// otlpAttributes returns the labels of series as attributes.
+func otlpAttributes(series *dto.Metric) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, len(series.GetLabel()))
+	for _, label := range series.GetLabel() {
+		attrs = append(attrs, attribute.String(label.GetName(), label.GetValue()))
+	}
+	return attribute.NewSet(attrs...)
+}
+
+// otlpTime returns the timestamp of series, as set with
+// -collect.timestamps, or now.
+func otlpTime(series *dto.Metric, now time.Time) time.Time {
+	if series.TimestampMs != nil {
+		return time.UnixMilli(series.GetTimestampMs())
+	}
+	return now
+}
+
+// otlpUnit returns the OTLP unit of the metric called name, or "" if its
+// name carries none.
+func otlpUnit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	for _, u := range otlpUnits {
+		if strings.HasSuffix(name, u.suffix) {
+			return u.unit
+		}
+	}
+	return ""
+}
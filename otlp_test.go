@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const otlpFixture = `CPU Temp         | 01h | ok  |  3.1 | 45 degrees C
+Inlet Airflow    | 02h | ok  |  7.1 | 30 CFM
+CPU Util         | 03h | ok  |  3.1 | 45 percent
+PS1 Status       | c8h | ok  | 10.1 | Presence detected
+12V              | 20h | ok  |  7.1 | 12.05 Volts
+`
+
+// otlpReceiver records the metrics pushed to it over OTLP/HTTP.
+type otlpReceiver struct {
+	mu      sync.Mutex
+	metrics map[string][]*metricpb.Metric
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export colmetricpb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, resource := range export.GetResourceMetrics() {
+		for _, scope := range resource.GetScopeMetrics() {
+			for _, m := range scope.GetMetrics() {
+				r.metrics[m.GetName()] = append(r.metrics[m.GetName()], m)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(nil)
+}
+
+func TestOTLPExport(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "sdr.txt")
+	if err := os.WriteFile(fixture, []byte(otlpFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	collector := ipmicollector.NewCollector(
+		ipmicollector.IPMIConfig{Host: "bmc1", Interface: "open"},
+		ipmicollector.WithRunner(ipmicollector.FileRunner{Path: fixture}),
+		ipmicollector.WithTemperatureUnit("fahrenheit"),
+		ipmicollector.WithSensorTypes("temperature", "airflow", "percent", "discrete"),
+	)
+	if err := collector.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector, prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_unrelated", Help: "Not pushed"}))
+
+	receiver := &otlpReceiver{metrics: make(map[string][]*metricpb.Metric)}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	stop, err := startOTLPExport(context.Background(), server.URL, time.Hour, registry, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shutting down flushes the metrics gathered so far.
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	for _, name := range []string{"ipmi_up", "ipmi_temperature_fahrenheit", "ipmi_airflow_cfm", "ipmi_sensor_percent", "ipmi_sensor_state"} {
+		if len(receiver.metrics[name]) == 0 {
+			t.Errorf("%s was not pushed", name)
+		}
+	}
+	for _, name := range []string{"ipmi_temperature_celsius", "ipmi_voltage_volts", "go_unrelated"} {
+		if len(receiver.metrics[name]) > 0 {
+			t.Errorf("%s was pushed", name)
+		}
+	}
+
+	temps := receiver.metrics["ipmi_temperature_fahrenheit"]
+	if len(temps) == 0 {
+		return
+	}
+	if unit := temps[0].GetUnit(); unit != "[degF]" {
+		t.Errorf("unit = %q, want [degF]", unit)
+	}
+	points := temps[0].GetGauge().GetDataPoints()
+	if len(points) != 1 {
+		t.Fatalf("got %d temperature data points, want 1", len(points))
+	}
+	if value := points[0].GetAsDouble(); value != 113 {
+		t.Errorf("temperature = %v, want 113", value)
+	}
+	attrs := make(map[string]string)
+	for _, kv := range points[0].GetAttributes() {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	if attrs["host"] != "bmc1" || attrs["sensor_name"] != "CPU Temp" || attrs["sensor_id"] != "01h" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wimwenigerkind/ipmi-prometheus-exporter/ipmicollector"
+)
+
+// targetSet holds the collectors of the background collection targets and
+// the config file they came from. Reloading the config file swaps both while
+// the collection loops and /ipmi keep reading them.
+type targetSet struct {
+	// ctx bounds the collections started for targets added by a reload.
+	ctx      context.Context
+	registry prometheus.Registerer
+	opts     []ipmicollector.Option
+	// metrics are the counters shared by the collectors, whose series of
+	// removed targets are deleted.
+	metrics *ipmicollector.Metrics
+	health  *readiness
+
+	mu         sync.Mutex
+	fileConfig *Config
+	// running is keyed by the target as configured, so a target whose
+	// settings change is replaced rather than kept.
+	running map[ipmicollector.IPMIConfig]*ipmicollector.Collector
+}
+
+// newTargetSet registers a collector for every target with registry. The
+// collectors count into metrics, as set up by opts.
+func newTargetSet(ctx context.Context, registry prometheus.Registerer, opts []ipmicollector.Option, metrics *ipmicollector.Metrics, health *readiness, fileConfig *Config, targets []ipmicollector.IPMIConfig) *targetSet {
+	s := &targetSet{
+		ctx:        ctx,
+		registry:   registry,
+		opts:       opts,
+		metrics:    metrics,
+		health:     health,
+		fileConfig: fileConfig,
+		running:    make(map[ipmicollector.IPMIConfig]*ipmicollector.Collector, len(targets)),
+	}
+	for _, config := range targets {
+		if _, ok := s.running[config]; ok {
+			continue
+		}
+		slog.Info("Connecting to IPMI host", "host", config.Host, "address", config.Address())
+		collector := newCollector(config, opts)
+		registry.MustRegister(collector)
+		s.running[config] = collector
+	}
+	return s
+}
+
+// target returns the settings of host if it is a current target, from the
+// config file or the environment.
+func (s *targetSet) target(host string) (ipmicollector.IPMIConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fileConfig != nil {
+		return s.fileConfig.Target(host)
+	}
+	host = ipmicollector.NormalizeHost(host)
+	for config := range s.running {
+		if config.Host == host {
+			return config, true
+		}
+	}
+	return ipmicollector.IPMIConfig{}, false
+}
+
+// collector returns the background collector of config, if it is a current
+// target.
+func (s *targetSet) collector(config ipmicollector.IPMIConfig) (*ipmicollector.Collector, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collector, ok := s.running[config]
+	return collector, ok
+}
+
+// collectors returns the collectors of the current targets.
+func (s *targetSet) collectors() []*ipmicollector.Collector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collectors := make([]*ipmicollector.Collector, 0, len(s.running))
+	for _, collector := range s.running {
+		collectors = append(collectors, collector)
+	}
+	return collectors
+}
+
+// reload re-reads -config.file and reconciles the running targets with it:
+// removed targets stop being collected and exported, and added ones are
+// collected right away instead of waiting for the next interval. A config
+// file that fails to load leaves everything as it was.
+func (s *targetSet) reload() error {
+	if *configFile == "" {
+		return fmt.Errorf("no -config.file to reload")
+	}
+	fileConfig, err := LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[ipmicollector.IPMIConfig]bool, len(fileConfig.Targets))
+	for _, config := range fileConfig.Targets {
+		wanted[config] = true
+	}
+	// Unregister first, so a target replaced by one with the same labels
+	// doesn't collide with its old collector.
+	for config, collector := range s.running {
+		if !wanted[config] {
+			slog.Info("Removing IPMI host", "host", config.Host)
+			s.registry.Unregister(collector)
+			s.metrics.Delete(collector.HostLabel(), collector.Config().InstanceName)
+			delete(s.running, config)
+		}
+	}
+	var added []*ipmicollector.Collector
+	for config := range wanted {
+		if _, ok := s.running[config]; ok {
+			continue
+		}
+		slog.Info("Connecting to IPMI host", "host", config.Host, "address", config.Address())
+		collector := newCollector(config, s.opts)
+		if err := s.registry.Register(collector); err != nil {
+			slog.Error("Failed to register IPMI host", "host", config.Host, "err", err)
+			continue
+		}
+		s.running[config] = collector
+		added = append(added, collector)
+	}
+	s.fileConfig = fileConfig
+
+	if len(added) > 0 {
+		go func() {
+			collectAll(s.ctx, added, *maxConcurrency, s.health)
+			if *collectBMC {
+				refreshBMCInfo(s.ctx, added)
+			}
+		}()
+	}
+	slog.Info("Reloaded config file", "file", *configFile, "targets", len(s.running), "added", len(added))
+	return nil
+}
+
+// reloadHandler serves POST /-/reload, answering 400 with the error if the
+// config file could not be reloaded.
+func (s *targetSet) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "reload requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.reload(); err != nil {
+		slog.Error("Failed to reload config file", "err", err)
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK\n"))
+}
+
+// reloadOnSIGHUP reloads the config file on every SIGHUP until ctx is
+// cancelled.
+func (s *targetSet) reloadOnSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := s.reload(); err != nil {
+					slog.Error("Failed to reload config file", "err", err)
+				}
+			}
+		}
+	}()
+}
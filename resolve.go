@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// hostLookupTimeout bounds a single reverse DNS lookup.
+	hostLookupTimeout = 2 * time.Second
+	// hostNameTTL is how long a lookup result, including a failed one, is
+	// reused, so on-demand scrapes don't hit DNS every time.
+	hostNameTTL = 10 * time.Minute
+)
+
+// addrResolver performs reverse DNS lookups. net.Resolver implements it.
+type addrResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// hostNameCache resolves the host labels exported with -collect.resolve-host.
+// Lookups of the same host share one DNS query, and run without holding mu so
+// a slow one doesn't hold up the hosts already cached.
+type hostNameCache struct {
+	resolver addrResolver
+	lookups  singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]hostNameEntry
+}
+
+type hostNameEntry struct {
+	name    string
+	expires time.Time
+}
+
+// hostNames is the cache used by newCollector.
+var hostNames = newHostNameCache(net.DefaultResolver)
+
+func newHostNameCache(resolver addrResolver) *hostNameCache {
+	return &hostNameCache{resolver: resolver, entries: make(map[string]hostNameEntry)}
+}
+
+// lookup returns the first name a reverse lookup of host returns, without
+// the trailing dot. Hosts that aren't IP addresses are returned as they are,
+// as is host if the lookup fails.
+func (c *hostNameCache) lookup(host string) string {
+	if _, err := netip.ParseAddr(host); err != nil {
+		return host
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.name
+	}
+
+	name, _, _ := c.lookups.Do(host, func() (any, error) {
+		return c.resolve(host), nil
+	})
+	return name.(string)
+}
+
+// resolve looks host up in DNS and caches the result.
+func (c *hostNameCache) resolve(host string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+	defer cancel()
+	name := host
+	names, err := c.resolver.LookupAddr(ctx, host)
+	switch {
+	case err != nil:
+		slog.Warn("Failed to resolve host name, using the address as host label", "host", host, "err", err)
+	case len(names) > 0:
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[host] = hostNameEntry{name: name, expires: time.Now().Add(hostNameTTL)}
+	return name
+}
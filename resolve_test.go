@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers reverse lookups from names, blocking the lookups of
+// 10.0.0.2 until release is closed after signalling on started.
+type fakeResolver struct {
+	names   map[string][]string
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+// LookupAddr implements addrResolver.
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.calls.Add(1)
+	if addr == "10.0.0.2" {
+		r.started <- struct{}{}
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return names, nil
+}
+
+func TestHostNameCache(t *testing.T) {
+	resolver := &fakeResolver{
+		names: map[string][]string{
+			"10.0.0.1": {"bmc1.example.com."},
+			"10.0.0.2": {"bmc2.example.com."},
+		},
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	cache := newHostNameCache(resolver)
+
+	tests := []struct {
+		host, want string
+	}{
+		{"10.0.0.1", "bmc1.example.com"},
+		{"10.0.0.1", "bmc1.example.com"},
+		{"10.0.0.9", "10.0.0.9"},
+		{"bmc3.example.com", "bmc3.example.com"},
+	}
+	for _, tt := range tests {
+		if got := cache.lookup(tt.host); got != tt.want {
+			t.Errorf("lookup(%s) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+	if calls := resolver.calls.Load(); calls != 2 {
+		t.Errorf("%d lookups, want one per address and the names cached", calls)
+	}
+
+	// Concurrent lookups of a slow host share one query, and don't hold up
+	// the hosts already cached.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := cache.lookup("10.0.0.2"); got != "bmc2.example.com" {
+				t.Errorf("lookup(10.0.0.2) = %s, want bmc2.example.com", got)
+			}
+		}()
+	}
+	<-resolver.started
+	done := make(chan string)
+	go func() { done <- cache.lookup("10.0.0.1") }()
+	select {
+	case got := <-done:
+		if got != "bmc1.example.com" {
+			t.Errorf("lookup(10.0.0.1) = %s, want bmc1.example.com", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lookup of a cached host waited for the slow lookup")
+	}
+	close(resolver.release)
+	wg.Wait()
+	if calls := resolver.calls.Load(); calls != 3 {
+		t.Errorf("%d lookups after the concurrent ones, want 3", calls)
+	}
+}
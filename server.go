@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// unixSocketPrefix marks a -web.listen-address that names a Unix domain
+// socket rather than a TCP address.
+const unixSocketPrefix = "unix:"
+
+// unixSocketMode is the permission set on a created Unix socket, letting a
+// local scraper in the socket's group connect.
+const unixSocketMode = 0o660
+
+const (
+	// serverReadHeaderTimeout bounds how long a client may take to send the
+	// request headers, so slow clients can't hold connections open.
+	serverReadHeaderTimeout = 10 * time.Second
+	// serverIdleTimeout is how long keep-alive connections are kept open
+	// between scrapes.
+	serverIdleTimeout = 2 * time.Minute
+)
+
+// newServer returns the HTTP server of the exporter serving handler. The
+// write timeout has to cover a whole on-demand collection from /ipmi, not
+// just writing the response.
+func newServer(handler http.Handler, readTimeout, writeTimeout time.Duration) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       serverIdleTimeout,
+	}
+}
+
+// metricsCompressions are the encodings the metrics endpoints offer, picked
+// from the Accept-Encoding of the scrape. gzip shrinks the output of a dense
+// chassis several times over.
+var metricsCompressions = []promhttp.Compression{promhttp.Gzip, promhttp.Identity}
+
+// metricsHandler serves the metrics of gatherer, in the OpenMetrics format
+// when the scraper asks for it. Both /metrics and /ipmi go through it, so
+// they negotiate the format and compression alike.
+func metricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics:   true,
+		OfferedCompressions: metricsCompressions,
+	})
+}
+
+// listenAndServe serves server on address, which is either a TCP host:port
+// or unix:/path/to/socket. It blocks until the server is shut down.
+func listenAndServe(server *http.Server, address, webConfigFile string, logger *slog.Logger) error {
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{address},
+		WebConfigFile:      &webConfigFile,
+	}
+
+	path, ok := strings.CutPrefix(address, unixSocketPrefix)
+	if !ok {
+		return web.ListenAndServe(server, webFlags, logger)
+	}
+
+	listener, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(path) }()
+
+	return web.Serve(listener, server, webFlags, logger)
+}
+
+// listenUnix listens on the Unix socket at path, replacing a stale socket
+// left behind by a previous run. Anything else at path is left alone.
+func listenUnix(path string) (net.Listener, error) {
+	switch info, err := os.Lstat(path); {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to check %s: %v", path, err)
+	case info.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	default:
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %v", path, err)
+	}
+	return listener, nil
+}